@@ -0,0 +1,123 @@
+// cmd/pedloy/common/run.go - Flags and options shared by the commands that run stacks
+package common
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/jaxxstorm/pedloy/pkg/auto"
+	"github.com/jaxxstorm/pedloy/pkg/config"
+	"github.com/jaxxstorm/pedloy/pkg/graph"
+	"github.com/jaxxstorm/pedloy/pkg/project"
+	"github.com/jaxxstorm/pedloy/pkg/util"
+)
+
+// AddRunFlags adds the flags shared by every command that runs stacks. includeDependents
+// is the default of --include-dependents.
+func AddRunFlags(cmd *cobra.Command, includeDependents bool) {
+	cmd.Flags().String("config", "projects.yml", "Path to the configuration file")
+	cmd.Flags().String("org", "", "The Pulumi org stacks live in")
+	cmd.Flags().String("path", "", "The path to Pulumi projects")
+	cmd.Flags().String("git-url", "", "The Git repository URL for projects")
+	cmd.Flags().String("git-branch", "", "The Git branch to use (defaults to the repository's default branch)")
+	cmd.Flags().String("git-ref", "", "A Git tag or commit SHA to use instead of the branch")
+	cmd.Flags().Bool("json", false, "Enable JSON logging")
+	cmd.Flags().Duration("stack-timeout", 0, "Maximum time each stack may take, e.g. 30m (0 means no timeout)")
+	cmd.Flags().Int("parallel", 0, "Maximum number of stacks to run at once (0 means no limit)")
+	cmd.Flags().StringSlice("stack", nil, "Only run stacks whose name matches these glob patterns (repeatable)")
+	cmd.Flags().StringSlice("target", nil, "Only run these projects or project:stack pairs (repeatable)")
+	cmd.Flags().Bool("include-dependencies", false, "Also run the stacks the targets depend on")
+	cmd.Flags().Bool("include-dependents", includeDependents, "Also run the stacks that depend on the targets")
+}
+
+// AddStateFlags adds the flags that record a run's progress so it can be resumed.
+func AddStateFlags(cmd *cobra.Command) {
+	cmd.Flags().String("state-file", ".pedloy-state.json", "Path to the run-state file used by --resume (empty disables it)")
+	cmd.Flags().Bool("resume", false, "Skip stacks that succeeded in the previous run recorded in the state file")
+}
+
+// AddReportFlags adds the flags that write a summary report of the run.
+func AddReportFlags(cmd *cobra.Command) {
+	cmd.Flags().String("report-file", "", "Path to write a summary report of the run to")
+	cmd.Flags().String("report-format", "json", "Format of the report: json, markdown or junit")
+}
+
+// LoadOptions loads and validates the configuration, then builds the run options from it
+// and the command's flags. Flags a command does not define are left at their zero value.
+func LoadOptions(v *viper.Viper) (*project.Config, auto.Options, error) {
+	// Load configuration
+	cfg, err := config.LoadConfig(v)
+	if err != nil {
+		return nil, auto.Options{}, fmt.Errorf("error loading config: %w", err)
+	}
+
+	// Validate dependencies
+	if err := util.ValidateDependencies(cfg.Projects); err != nil {
+		return nil, auto.Options{}, fmt.Errorf("%w: invalid dependencies: %w", config.ErrInvalidConfig, err)
+	}
+
+	// Set up project source
+	source := project.ProjectSource{
+		IsGit:     v.GetString("git-url") != "",
+		GitURL:    v.GetString("git-url"),
+		GitBranch: v.GetString("git-branch"),
+		GitRef:    v.GetString("git-ref"),
+		LocalPath: v.GetString("path"),
+	}
+
+	if parallel := v.GetInt("parallel"); parallel > 0 {
+		cfg.Concurrency.Parallel = parallel
+	}
+	opts := auto.Options{
+		Org:          v.GetString("org"),
+		Source:       source,
+		JSONLogger:   v.GetBool("json"),
+		Concurrency:  cfg.Concurrency,
+		LogEnvValues: cfg.LogEnvValues,
+		StackTimeout: v.GetDuration("stack-timeout"),
+		Retry:        cfg.RetryPolicy,
+		StateFile:    v.GetString("state-file"),
+		Resume:       v.GetBool("resume"),
+		Selection: graph.Selection{
+			Stacks:              v.GetStringSlice("stack"),
+			Targets:             v.GetStringSlice("target"),
+			IncludeDependencies: v.GetBool("include-dependencies"),
+			IncludeDependents:   v.GetBool("include-dependents"),
+		},
+	}
+	if opts.Resume && opts.StateFile == "" {
+		return nil, auto.Options{}, fmt.Errorf("%w: --resume needs a --state-file", config.ErrInvalidConfig)
+	}
+	return cfg, opts, nil
+}
+
+// Report is the summary report requested with --report-file and --report-format.
+type Report struct {
+	File   string
+	Format string
+}
+
+// NewReport reads the report flags, checking the format before anything runs.
+func NewReport(v *viper.Viper) (Report, error) {
+	r := Report{File: v.GetString("report-file"), Format: v.GetString("report-format")}
+	if r.File != "" {
+		if err := auto.ValidateReportFormat(r.Format); err != nil {
+			return Report{}, err
+		}
+	}
+	return r, nil
+}
+
+// Write writes the report of a run, if one was requested, and joins any failure to err.
+func (r Report) Write(result *auto.Result, err error) error {
+	if result == nil || r.File == "" {
+		return err
+	}
+	if werr := auto.WriteReportFile(r.File, result, r.Format); werr != nil {
+		return errors.Join(err, werr)
+	}
+	return err
+}
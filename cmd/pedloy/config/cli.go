@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/jaxxstorm/pedloy/pkg/config"
+	"github.com/jaxxstorm/pedloy/pkg/project"
+)
+
+// secretValue is shown in place of secret env and config values.
+const secretValue = "[secret]"
+
+// hiddenValue is shown in place of values read from env files or the calling environment,
+// unless --show-values is passed.
+const hiddenValue = "[hidden]"
+
+// Command creates the config command.
+func Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+	cmd.AddCommand(resolveCommand())
+	return cmd
+}
+
+func resolveCommand() *cobra.Command {
+	v := viper.New()
+
+	cmd := &cobra.Command{
+		Use:   "resolve project:stack...",
+		Short: "Show the effective settings of stacks",
+		Long:  "Print the env and Pulumi config a stack runs with after merging the global defaults, project and stack settings, and where each value came from. Secret values are always masked; values read from env files or the calling environment are hidden unless --show-values is passed",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Bind flags to viper
+			v.BindPFlags(cmd.Flags())
+
+			// Load configuration
+			cfg, err := config.LoadConfig(v)
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+
+			for i, arg := range args {
+				name, stack, ok := strings.Cut(arg, ":")
+				if !ok || name == "" || stack == "" {
+					return fmt.Errorf("invalid stack %q, expected project:stack", arg)
+				}
+				p, ok := findProject(cfg.Projects, name)
+				if !ok {
+					return fmt.Errorf("%w: project %s not found", config.ErrInvalidConfig, name)
+				}
+				if _, ok := p.Stack(stack); !ok {
+					return fmt.Errorf("%w: project %s has no stack %s", config.ErrInvalidConfig, name, stack)
+				}
+
+				env, err := p.ResolveStackEnv(stack)
+				if err != nil {
+					return fmt.Errorf("%w: %s: %w", config.ErrInvalidConfig, arg, err)
+				}
+				stackConfig, err := p.ResolveStackConfig(stack)
+				if err != nil {
+					return fmt.Errorf("%w: %s: %w", config.ErrInvalidConfig, arg, err)
+				}
+
+				if i > 0 {
+					fmt.Println()
+				}
+				writeResolved(os.Stdout, p, stack, env, stackConfig, v.GetBool("show-values"))
+			}
+			return nil
+		},
+	}
+
+	// Add flags
+	cmd.Flags().String("config", "projects.yml", "Path to the configuration file")
+	cmd.Flags().Bool("show-values", false, "Show values read from env files or the calling environment (secret values stay masked)")
+
+	return cmd
+}
+
+func findProject(projects []project.Project, name string) (project.Project, bool) {
+	for _, p := range projects {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return project.Project{}, false
+}
+
+// writeResolved prints the effective settings of a stack with secret values masked. External
+// values are hidden too unless showValues is set, as they may hold credentials that are
+// not marked secret.
+func writeResolved(w io.Writer, p project.Project, stack string, env, cfg map[string]project.Resolved, showValues bool) {
+	fmt.Fprintf(w, "%s:%s\n", p.Name, stack)
+	if profile := env["AWS_PROFILE"].Value; profile != "" {
+		fmt.Fprintf(w, "  aws_profile: %s\n", profile)
+	}
+	if timeout := p.StackTimeout(stack); timeout > 0 {
+		fmt.Fprintf(w, "  timeout: %s\n", timeout)
+	}
+
+	section := func(title string, values map[string]project.Resolved) {
+		fmt.Fprintf(w, "  %s:\n", title)
+		if len(values) == 0 {
+			fmt.Fprintln(w, "    (none)")
+			return
+		}
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			value := values[k].Value
+			switch {
+			case values[k].Secret:
+				value = secretValue
+			case values[k].External && !showValues:
+				value = hiddenValue
+			}
+			fmt.Fprintf(w, "    %s=%s (%s)\n", k, value, values[k].Source)
+		}
+	}
+	section("env", env)
+	section("config", cfg)
+}
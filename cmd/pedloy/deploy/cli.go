@@ -6,9 +6,8 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/jaxxstorm/pedloy/cmd/pedloy/common"
 	"github.com/jaxxstorm/pedloy/pkg/auto"
-	"github.com/jaxxstorm/pedloy/pkg/config"
-	"github.com/jaxxstorm/pedloy/pkg/project"
 	"github.com/jaxxstorm/pedloy/pkg/util"
 )
 
@@ -23,38 +22,28 @@ func Command() *cobra.Command {
 			// Bind flags to viper
 			v.BindPFlags(cmd.Flags())
 
-			// Load configuration
-			projects, err := config.LoadConfig(v)
+			cfg, opts, err := common.LoadOptions(v)
 			if err != nil {
-				return fmt.Errorf("error loading config: %w", err)
+				return err
 			}
-
-			// Validate dependencies
-			if err := util.ValidateDependencies(projects); err != nil {
-				return fmt.Errorf("invalid dependencies: %w", err)
-			}
-
-			// Set up project source
-			source := project.ProjectSource{
-				IsGit:     v.GetString("git-url") != "",
-				GitURL:    v.GetString("git-url"),
-				GitBranch: v.GetString("git-branch"),
-				LocalPath: v.GetString("path"),
+			report, err := common.NewReport(v)
+			if err != nil {
+				return err
 			}
-
-			org := v.GetString("org")
-			jsonLogger := v.GetBool("json")
 			preview := v.GetBool("preview")
 
 			// Perform preview or deployment
 			if preview {
-				err = util.PreviewExecution(projects, "deploy")
+				err = util.PreviewExecution(cfg.Projects, "deploy", opts.Selection)
 				if err != nil {
 					return fmt.Errorf("preview failed: %w", err)
 				}
 			} else {
 				errorFile := v.GetString("error-file")
-				auto.Deploy(org, projects, source, jsonLogger, errorFile)
+				result, err := auto.Deploy(cmd.Context(), cfg.Projects, opts, errorFile)
+				if err := report.Write(result, err); err != nil {
+					return fmt.Errorf("deploy failed: %w", err)
+				}
 			}
 
 			return nil
@@ -62,13 +51,10 @@ func Command() *cobra.Command {
 	}
 
 	// Add flags
-	cmd.Flags().String("config", "projects.yml", "Path to the configuration file")
-	cmd.Flags().String("org", "", "The Pulumi org stacks live in")
-	cmd.Flags().String("path", "", "The path to Pulumi projects")
-	cmd.Flags().String("git-url", "", "The Git repository URL for projects")
-	cmd.Flags().String("git-branch", "main", "The Git branch to use")
+	common.AddRunFlags(cmd, false)
+	common.AddStateFlags(cmd)
+	common.AddReportFlags(cmd)
 	cmd.Flags().Bool("preview", false, "Preview the deployment plan")
-	cmd.Flags().Bool("json", false, "Enable JSON logging")
 	cmd.Flags().String("error-file", "", "Path to error log file (optional)")
 
 	return cmd
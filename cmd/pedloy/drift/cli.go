@@ -0,0 +1,60 @@
+package drift
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/jaxxstorm/pedloy/cmd/pedloy/common"
+	"github.com/jaxxstorm/pedloy/pkg/auto"
+)
+
+// Command creates the drift command.
+func Command() *cobra.Command {
+	v := viper.New()
+
+	cmd := &cobra.Command{
+		Use:   "drift",
+		Short: "Detect drift in Pulumi stacks",
+		Long:  "Preview a refresh of every stack in dependency order and report stacks whose infrastructure differs from their state, without changing it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Bind flags to viper
+			v.BindPFlags(cmd.Flags())
+
+			cfg, opts, err := common.LoadOptions(v)
+			if err != nil {
+				return err
+			}
+
+			result, err := auto.Drift(cmd.Context(), cfg.Projects, opts)
+			if result != nil {
+				var w io.Writer = os.Stdout
+				if output := v.GetString("output"); output != "" {
+					f, ferr := os.Create(output)
+					if ferr != nil {
+						return fmt.Errorf("failed to create drift report: %w", ferr)
+					}
+					defer f.Close()
+					w = f
+				}
+				if werr := auto.NewDriftReport(result).Write(w); werr != nil {
+					return fmt.Errorf("failed to write drift report: %w", werr)
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("drift check failed: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	// Add flags
+	common.AddRunFlags(cmd, false)
+	cmd.Flags().String("output", "", "Path to write the JSON drift report to (defaults to stdout; logs always go to stderr)")
+
+	return cmd
+}
@@ -0,0 +1,66 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/jaxxstorm/pedloy/pkg/config"
+	"github.com/jaxxstorm/pedloy/pkg/graph"
+	"github.com/jaxxstorm/pedloy/pkg/util"
+)
+
+// Command creates the graph command.
+func Command() *cobra.Command {
+	v := viper.New()
+
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Export the dependency graph",
+		Long:  "Export the project:stack dependency graph as Graphviz DOT, Mermaid or JSON, with stages shown as subgraphs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Bind flags to viper
+			v.BindPFlags(cmd.Flags())
+
+			// Load configuration
+			cfg, err := config.LoadConfig(v)
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			projects := cfg.Projects
+
+			// Validate dependencies
+			if err := util.ValidateDependencies(projects); err != nil {
+				return fmt.Errorf("%w: invalid dependencies: %w", config.ErrInvalidConfig, err)
+			}
+
+			g, err := graph.Build(projects)
+			if err != nil {
+				return fmt.Errorf("%w: %w", config.ErrInvalidConfig, err)
+			}
+
+			var w io.Writer = os.Stdout
+			if output := v.GetString("output"); output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					return fmt.Errorf("failed to create graph file: %w", err)
+				}
+				defer f.Close()
+				w = f
+			}
+
+			return g.Export(w, v.GetString("format"))
+		},
+	}
+
+	// Add flags
+	cmd.Flags().String("config", "projects.yml", "Path to the configuration file")
+	cmd.Flags().String("format", "dot", fmt.Sprintf("Output format (%s)", strings.Join(graph.Formats, ", ")))
+	cmd.Flags().String("output", "", "Path to write the graph to (defaults to stdout)")
+
+	return cmd
+}
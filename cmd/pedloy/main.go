@@ -3,17 +3,27 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"context"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	configcmd "github.com/jaxxstorm/pedloy/cmd/pedloy/config"
 	"github.com/jaxxstorm/pedloy/cmd/pedloy/deploy"
 	"github.com/jaxxstorm/pedloy/cmd/pedloy/destroy"
+	"github.com/jaxxstorm/pedloy/cmd/pedloy/drift"
+	"github.com/jaxxstorm/pedloy/cmd/pedloy/graph"
+	"github.com/jaxxstorm/pedloy/cmd/pedloy/preview"
+	"github.com/jaxxstorm/pedloy/cmd/pedloy/refresh"
 	"github.com/jaxxstorm/pedloy/cmd/pedloy/version"
+	"github.com/jaxxstorm/pedloy/pkg/auto"
+	"github.com/jaxxstorm/pedloy/pkg/config"
 	"github.com/jaxxstorm/pedloy/pkg/contract"
 
 	pkgver "github.com/jaxxstorm/pedloy/pkg/version"
@@ -26,6 +36,31 @@ var (
 	debug       bool
 )
 
+// Exit codes returned by pedloy.
+const (
+	exitError         = 1
+	exitStacksFailed  = 2
+	exitInvalidConfig = 3
+	exitDrift         = 4
+	exitCancelled     = 130
+)
+
+// exitCode maps an error returned by a command to the process exit code.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, auto.ErrCancelled):
+		return exitCancelled
+	case errors.Is(err, config.ErrInvalidConfig):
+		return exitInvalidConfig
+	case errors.Is(err, auto.ErrStacksFailed):
+		return exitStacksFailed
+	case errors.Is(err, auto.ErrDriftDetected):
+		return exitDrift
+	default:
+		return exitError
+	}
+}
+
 func configureCLI() *cobra.Command {
 	v := viper.New()
 
@@ -48,6 +83,11 @@ func configureCLI() *cobra.Command {
 	// Add subcommands
 	rootCommand.AddCommand(deploy.Command())
 	rootCommand.AddCommand(destroy.Command())
+	rootCommand.AddCommand(preview.Command())
+	rootCommand.AddCommand(refresh.Command())
+	rootCommand.AddCommand(drift.Command())
+	rootCommand.AddCommand(graph.Command())
+	rootCommand.AddCommand(configcmd.Command())
 	rootCommand.AddCommand(version.Command())
 
 	// Persistent Flags
@@ -60,9 +100,39 @@ func configureCLI() *cobra.Command {
 	return rootCommand
 }
 
+// signalContext returns a context that is cancelled on the first SIGINT or SIGTERM, so no
+// new stacks are started and running ones are cancelled cleanly. A second signal exits immediately.
+func signalContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-signals:
+		case <-ctx.Done():
+			return
+		}
+		contract.IgnoreIoError(fmt.Fprintln(os.Stderr, "Interrupted, cancelling running stacks. Send another signal to exit immediately."))
+		cancel()
+
+		<-signals
+		contract.IgnoreIoError(fmt.Fprintln(os.Stderr, "Exiting immediately, stacks may be left locked."))
+		os.Exit(exitCancelled)
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
 func main() {
-	if err := fang.Execute(context.Background(), configureCLI(), fang.WithVersion(pkgver.GetVersion())); err != nil {
+	ctx, stop := signalContext()
+	err := fang.Execute(ctx, configureCLI(), fang.WithVersion(pkgver.GetVersion()))
+	stop()
+	if err != nil {
 		contract.IgnoreIoError(fmt.Fprintf(os.Stderr, "%v\n", err))
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
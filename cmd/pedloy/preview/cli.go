@@ -0,0 +1,55 @@
+package preview
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/jaxxstorm/pedloy/cmd/pedloy/common"
+	"github.com/jaxxstorm/pedloy/pkg/auto"
+)
+
+// Command creates the preview command.
+func Command() *cobra.Command {
+	v := viper.New()
+
+	cmd := &cobra.Command{
+		Use:   "preview",
+		Short: "Preview changes to Pulumi stacks",
+		Long:  "Run pulumi preview against every stack in dependency order and summarise the changes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Bind flags to viper
+			v.BindPFlags(cmd.Flags())
+
+			cfg, opts, err := common.LoadOptions(v)
+			if err != nil {
+				return err
+			}
+			report, err := common.NewReport(v)
+			if err != nil {
+				return err
+			}
+
+			result, err := auto.Preview(cmd.Context(), cfg.Projects, opts)
+			if result != nil {
+				fmt.Println()
+				if werr := auto.WriteChangeSummary(os.Stdout, result); werr != nil {
+					return fmt.Errorf("failed to write change summary: %w", werr)
+				}
+			}
+			if err := report.Write(result, err); err != nil {
+				return fmt.Errorf("preview failed: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	// Add flags
+	common.AddRunFlags(cmd, false)
+	common.AddReportFlags(cmd)
+
+	return cmd
+}
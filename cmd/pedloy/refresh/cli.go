@@ -0,0 +1,61 @@
+package refresh
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/jaxxstorm/pedloy/cmd/pedloy/common"
+	"github.com/jaxxstorm/pedloy/pkg/auto"
+)
+
+// Command creates the refresh command.
+func Command() *cobra.Command {
+	v := viper.New()
+
+	cmd := &cobra.Command{
+		Use:   "refresh",
+		Short: "Refresh Pulumi stacks",
+		Long:  "Run pulumi refresh against every stack in dependency order and report which stacks drifted",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Bind flags to viper
+			v.BindPFlags(cmd.Flags())
+
+			cfg, opts, err := common.LoadOptions(v)
+			if err != nil {
+				return err
+			}
+			report, err := common.NewReport(v)
+			if err != nil {
+				return err
+			}
+
+			result, err := auto.Refresh(cmd.Context(), cfg.Projects, opts)
+			if result != nil {
+				fmt.Println()
+				if werr := auto.WriteChangeSummary(os.Stdout, result); werr != nil {
+					return fmt.Errorf("failed to write change summary: %w", werr)
+				}
+
+				drifted := result.Drifted()
+				fmt.Printf("\n%d stack(s) drifted\n", len(drifted))
+				for _, d := range drifted {
+					fmt.Printf("- %s\n", d.Vertex)
+				}
+			}
+			if err := report.Write(result, err); err != nil {
+				return fmt.Errorf("refresh failed: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	// Add flags
+	common.AddRunFlags(cmd, false)
+	common.AddReportFlags(cmd)
+
+	return cmd
+}
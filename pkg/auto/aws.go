@@ -0,0 +1,96 @@
+// pkg/auto/aws.go - Check that the AWS profiles used by stacks exist locally
+package auto
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	proj "github.com/jaxxstorm/pedloy/pkg/project"
+)
+
+// awsConfigFiles returns the AWS shared config and credentials file paths, honouring the
+// same environment variables as the AWS CLI and SDKs.
+func awsConfigFiles() (string, string) {
+	home, _ := os.UserHomeDir()
+	config := os.Getenv("AWS_CONFIG_FILE")
+	if config == "" {
+		config = filepath.Join(home, ".aws", "config")
+	}
+	credentials := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credentials == "" {
+		credentials = filepath.Join(home, ".aws", "credentials")
+	}
+	return config, credentials
+}
+
+// readAWSProfiles adds the profile names defined in an AWS config or credentials file to
+// profiles. In the config file profiles are written as [profile name], except for
+// [default]; in the credentials file they are written as [name]. A missing file defines
+// no profiles.
+func readAWSProfiles(path string, isConfig bool, profiles map[string]bool) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+			continue
+		}
+		name := strings.TrimSpace(line[1 : len(line)-1])
+		if isConfig && name != "default" {
+			// Other section types, such as [sso-session name], are not profiles
+			rest, ok := strings.CutPrefix(name, "profile ")
+			if !ok {
+				continue
+			}
+			name = strings.TrimSpace(rest)
+		}
+		profiles[name] = true
+	}
+	return scanner.Err()
+}
+
+// checkAWSProfiles returns an error naming every stack whose AWS profile is not defined in
+// the local AWS config or credentials file. envs holds each stack's resolved env, whose
+// AWS_PROFILE is the profile the stack runs with.
+func checkAWSProfiles(vertices []string, envs map[string]proj.EnvMap) error {
+	var missing []string
+	var profiles map[string]bool
+	config, credentials := awsConfigFiles()
+	for _, vertex := range vertices {
+		profile := envs[vertex]["AWS_PROFILE"].Value
+		if profile == "" {
+			continue
+		}
+
+		// Only read the files once a stack actually uses a profile
+		if profiles == nil {
+			profiles = make(map[string]bool)
+			if err := readAWSProfiles(config, true, profiles); err != nil {
+				return fmt.Errorf("failed to read AWS config file: %w", err)
+			}
+			if err := readAWSProfiles(credentials, false, profiles); err != nil {
+				return fmt.Errorf("failed to read AWS credentials file: %w", err)
+			}
+		}
+		if !profiles[profile] {
+			missing = append(missing, fmt.Sprintf("%s (%s)", vertex, profile))
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("AWS profiles not found in %s or %s for: %s", config, credentials, strings.Join(missing, ", "))
+	}
+	return nil
+}
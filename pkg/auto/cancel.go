@@ -0,0 +1,57 @@
+// pkg/auto/cancel.go - Cancel in-flight Pulumi operations cleanly when a run is interrupted
+package auto
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"go.uber.org/zap"
+)
+
+// timeoutKillGrace is how long an operation that has timed out is given to exit after
+// `pulumi cancel` before its Pulumi process is killed.
+const timeoutKillGrace = time.Minute
+
+// cancelOnDone returns a context for a Pulumi operation that is not cancelled along with ctx.
+// The Automation API kills the Pulumi process outright when its context ends, which leaves
+// the stack locked mid-update. Instead, when ctx is cancelled the operation is cancelled
+// through `pulumi cancel` and allowed to exit on its own. If ctx ended because the stack
+// timed out, the process is killed after a grace period so a hung stack cannot block the
+// run. Call stop once the operation returns.
+func cancelOnDone(ctx context.Context, s auto.Stack, logger *zap.Logger) (context.Context, func()) {
+	opCtx, kill := context.WithCancel(context.WithoutCancel(ctx))
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stopped:
+			return
+		}
+
+		timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+		if timedOut {
+			logger.Warn("Stack timed out, cancelling stack operation")
+		} else {
+			logger.Warn("Run interrupted, cancelling stack operation")
+		}
+		if err := s.Cancel(context.Background()); err != nil {
+			logger.Error("Failed to cancel stack operation", zap.Error(err))
+		}
+		if !timedOut {
+			return
+		}
+
+		select {
+		case <-time.After(timeoutKillGrace):
+			logger.Error("Stack operation did not exit after cancelling, killing it")
+			kill()
+		case <-stopped:
+		}
+	}()
+	return opCtx, func() {
+		close(stopped)
+		kill()
+	}
+}
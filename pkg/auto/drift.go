@@ -0,0 +1,137 @@
+// pkg/auto/drift.go - Detect drift between real infrastructure and Pulumi state
+package auto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	proj "github.com/jaxxstorm/pedloy/pkg/project"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optrefresh"
+	"go.uber.org/zap"
+)
+
+// ErrDriftDetected is returned when one or more stacks have drifted from their state.
+var ErrDriftDetected = errors.New("drift detected")
+
+// DriftReport is the machine-readable result of a drift check.
+type DriftReport struct {
+	Drifted bool         `json:"drifted"`
+	Stacks  []StackDrift `json:"stacks"`
+}
+
+// StackDrift is the drift check result for a single project:stack vertex.
+type StackDrift struct {
+	Stack   string         `json:"stack"`
+	Status  Status         `json:"status"`
+	Drifted bool           `json:"drifted"`
+	Changes map[string]int `json:"changes,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// NewDriftReport builds a drift report from the result of a drift check.
+func NewDriftReport(result *Result) DriftReport {
+	report := DriftReport{Stacks: []StackDrift{}}
+	for _, v := range result.Vertices {
+		drift := StackDrift{
+			Stack:   v.Vertex,
+			Status:  v.Status,
+			Drifted: hasChanges(v.Changes),
+			Changes: v.Changes,
+		}
+		if v.Err != nil {
+			drift.Error = v.Err.Error()
+		}
+		report.Drifted = report.Drifted || drift.Drifted
+		report.Stacks = append(report.Stacks, drift)
+	}
+	return report
+}
+
+// Write writes the report as indented JSON.
+func (r DriftReport) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// driftStack previews a refresh of the stack and returns the changes it would make to the
+// state. Nothing is written to the state, so drift stays visible until it is dealt with. A
+// stack that does not exist is an error rather than being created. Progress goes to stderr,
+// leaving stdout for the report.
+func driftStack(ctx context.Context, run stackRun, org string, jsonLog bool) (map[string]int, error) {
+	logger := run.logger
+
+	s, err := selectExistingRunStack(ctx, org, run)
+	if err != nil {
+		logger.Error("Failed to select stack", zap.Error(err))
+		return nil, err
+	}
+	if err := applyStackConfig(ctx, s, run); err != nil {
+		logger.Error("Failed to set stack config", zap.Error(err))
+		return nil, err
+	}
+	logger.Info("Checking stack for drift")
+
+	var res auto.PreviewResult
+	if jsonLog {
+		eventChannel := make(chan events.EngineEvent)
+		go processEvents(logger, eventChannel)
+		res, err = s.PreviewRefresh(ctx, optrefresh.EventStreams(eventChannel))
+	} else {
+		out, flush := run.redact.Writer(os.Stderr)
+		res, err = s.PreviewRefresh(ctx, optrefresh.ProgressStreams(out))
+		flush()
+	}
+	if err != nil {
+		logger.Error("Failed to check stack for drift", zap.Error(err))
+		return nil, err
+	}
+
+	changes := make(map[string]int, len(res.ChangeSummary))
+	for op, count := range res.ChangeSummary {
+		changes[string(op)] = count
+	}
+	if hasChanges(changes) {
+		logger.Warn("Drift detected in stack", zap.Any("changes", changes))
+	} else {
+		logger.Info("No drift detected in stack")
+	}
+	return changes, nil
+}
+
+// Drift previews a refresh of every stack in dependency order and reports the stacks whose
+// real infrastructure differs from their state. The state is never changed, so repeated
+// checks keep reporting drift until it is fixed. The returned error wraps ErrDriftDetected
+// if any stack drifted and every check completed. Logs and progress are written to stderr,
+// so the report can be written to stdout.
+func Drift(ctx context.Context, projects []proj.Project, opts Options) (*Result, error) {
+	// Create a logger with a global field for drift checks
+	logger := newOutputLogger(os.Stderr, zap.String("operation", "drift"))
+	defer logger.Sync()
+
+	op := operation{name: "drift", noun: "Drift check", verb: "check drift of"}
+	result, err := walk(ctx, logger, op, projects, opts, func(ctx context.Context, run stackRun) (map[string]int, error) {
+		return driftStack(ctx, run, opts.Org, opts.JSONLogger)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	var drifted []string
+	for _, v := range result.Drifted() {
+		drifted = append(drifted, v.Vertex)
+	}
+	if len(drifted) > 0 {
+		logger.Warn("Drift detected", zap.Strings("drifted", drifted))
+		return result, fmt.Errorf("%w in %d stack(s)", ErrDriftDetected, len(drifted))
+	}
+
+	logger.Info("No drift detected")
+	return result, nil
+}
@@ -0,0 +1,88 @@
+// pkg/auto/limits.go - Bound how many stacks run at once, globally and per project or label
+package auto
+
+import (
+	proj "github.com/jaxxstorm/pedloy/pkg/project"
+)
+
+// limiter tracks running vertices against the global limit and the limits of the groups
+// (projects and labels) each vertex belongs to. A nil limiter allows everything. It is
+// only used from the scheduler's goroutine, so it needs no locking.
+type limiter struct {
+	global  int
+	total   int
+	limits  map[string]int
+	groups  map[string][]string
+	running map[string]int
+}
+
+// newLimiter returns a limiter for the given vertices, or nil if no limits are configured.
+func newLimiter(concurrency proj.Concurrency, projects []proj.Project, vertices []string) *limiter {
+	l := &limiter{
+		global:  concurrency.Parallel,
+		limits:  make(map[string]int),
+		groups:  make(map[string][]string),
+		running: make(map[string]int),
+	}
+	for label, limit := range concurrency.Labels {
+		if limit > 0 {
+			l.limits["label:"+label] = limit
+		}
+	}
+	for _, vertex := range vertices {
+		project, _ := findProject(projects, vertex)
+		if project.Parallel > 0 {
+			group := "project:" + project.Name
+			l.limits[group] = project.Parallel
+			l.groups[vertex] = append(l.groups[vertex], group)
+		}
+		for _, label := range project.Labels {
+			if _, ok := l.limits["label:"+label]; ok {
+				l.groups[vertex] = append(l.groups[vertex], "label:"+label)
+			}
+		}
+	}
+
+	if l.global <= 0 && len(l.limits) == 0 {
+		return nil
+	}
+	return l
+}
+
+// allows reports whether the vertex can start without exceeding any limit.
+func (l *limiter) allows(vertex string) bool {
+	if l == nil {
+		return true
+	}
+	if l.global > 0 && l.total >= l.global {
+		return false
+	}
+	for _, group := range l.groups[vertex] {
+		if l.running[group] >= l.limits[group] {
+			return false
+		}
+	}
+	return true
+}
+
+// acquire records that the vertex has started.
+func (l *limiter) acquire(vertex string) {
+	if l == nil {
+		return
+	}
+	l.total++
+	for _, group := range l.groups[vertex] {
+		l.running[group]++
+	}
+}
+
+// release records that the vertex has finished.
+func (l *limiter) release(vertex string) {
+	if l == nil {
+		return
+	}
+	l.total--
+	for _, group := range l.groups[vertex] {
+		l.running[group]--
+	}
+}
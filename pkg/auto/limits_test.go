@@ -0,0 +1,69 @@
+package auto
+
+import (
+	"testing"
+
+	proj "github.com/jaxxstorm/pedloy/pkg/project"
+)
+
+func TestNewLimiterWithoutLimits(t *testing.T) {
+	projects := []proj.Project{{Name: "app", Labels: []string{"db"}, Stacks: proj.Stacks{{Name: "dev"}}}}
+	// A label limit of zero and an unused label mean no limit
+	concurrency := proj.Concurrency{Labels: map[string]int{"db": 0, "other": 2}}
+
+	l := newLimiter(concurrency, projects, []string{"app:dev"})
+	if !l.allows("app:dev") {
+		t.Fatal("limiter without limits for app:dev refused it")
+	}
+	if got := newLimiter(proj.Concurrency{}, projects, []string{"app:dev"}); got != nil {
+		t.Errorf("expected a nil limiter without any limits, got %+v", got)
+	}
+	// A nil limiter allows everything and ignores acquire and release
+	var nilLimiter *limiter
+	nilLimiter.acquire("app:dev")
+	nilLimiter.release("app:dev")
+	if !nilLimiter.allows("app:dev") {
+		t.Error("nil limiter refused a vertex")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	projects := []proj.Project{
+		{Name: "net", Parallel: 2, Labels: []string{"db"}, Stacks: proj.Stacks{{Name: "a"}, {Name: "b"}, {Name: "c"}}},
+		{Name: "app", Labels: []string{"db"}, Stacks: proj.Stacks{{Name: "a"}}},
+		{Name: "web", Stacks: proj.Stacks{{Name: "a"}, {Name: "b"}}},
+	}
+	vertices := []string{"net:a", "net:b", "net:c", "app:a", "web:a", "web:b"}
+	l := newLimiter(proj.Concurrency{Parallel: 4, Labels: map[string]int{"db": 3}}, projects, vertices)
+
+	steps := []struct {
+		action string
+		vertex string
+		allows map[string]bool
+	}{
+		{action: "acquire", vertex: "net:a", allows: map[string]bool{"net:b": true, "app:a": true, "web:a": true}},
+		{action: "acquire", vertex: "net:b", allows: map[string]bool{"net:c": false, "app:a": true, "web:a": true}},
+		// The db label is now full, but unlabelled stacks still run under the global limit
+		{action: "acquire", vertex: "app:a", allows: map[string]bool{"net:c": false, "web:a": true}},
+		{action: "acquire", vertex: "web:a", allows: map[string]bool{"web:b": false}},
+		// Releasing a project slot frees the project and label limits, and the global one
+		{action: "release", vertex: "net:a", allows: map[string]bool{"net:c": true, "web:b": true}},
+		{action: "release", vertex: "net:b", allows: map[string]bool{"net:c": true}},
+	}
+	for _, step := range steps {
+		switch step.action {
+		case "acquire":
+			if !l.allows(step.vertex) {
+				t.Fatalf("%s was refused before acquiring it", step.vertex)
+			}
+			l.acquire(step.vertex)
+		case "release":
+			l.release(step.vertex)
+		}
+		for vertex, want := range step.allows {
+			if got := l.allows(vertex); got != want {
+				t.Errorf("after %s %s: allows(%s) = %v, want %v", step.action, step.vertex, vertex, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+// pkg/auto/options.go - Options shared by every run across the dependency graph
+package auto
+
+import (
+	"time"
+
+	"github.com/jaxxstorm/pedloy/pkg/graph"
+	proj "github.com/jaxxstorm/pedloy/pkg/project"
+)
+
+// Options configure a run across the dependency graph.
+type Options struct {
+	// Org is the Pulumi org stacks live in.
+	Org string
+	// Source is where the Pulumi projects are read from.
+	Source proj.ProjectSource
+	// JSONLogger streams engine events as JSON log lines instead of progress output.
+	JSONLogger bool
+	// Selection restricts the run to part of the graph.
+	Selection graph.Selection
+	// Concurrency limits how many stacks run at once.
+	Concurrency proj.Concurrency
+	// StackTimeout bounds how long each stack may take. Stack and project timeouts override it.
+	StackTimeout time.Duration
+	// Retry is the global retry policy. Stacks can override it.
+	Retry proj.RetryPolicy
+	// LogEnvValues lists the env var names whose values may be logged. Other values are redacted.
+	LogEnvValues []string
+	// StateFile is where the outcome of each stack is recorded. Empty disables the run state.
+	StateFile string
+	// Resume skips stacks that already succeeded according to StateFile.
+	Resume bool
+}
@@ -0,0 +1,155 @@
+// pkg/auto/outputs.go - Pass upstream stack outputs into downstream stack config
+package auto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	proj "github.com/jaxxstorm/pedloy/pkg/project"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"go.uber.org/zap"
+)
+
+// errNotFound marks an upstream stack or output that does not exist, usually because the
+// upstream stack has not been deployed yet.
+var errNotFound = errors.New("not found")
+
+// outputResolver reads upstream stack outputs for configFrom entries. Outputs of stacks
+// deployed during this run are cached; anything else is read from the stack itself.
+type outputResolver struct {
+	org      string
+	projects []proj.Project
+	// allowMissing skips configFrom keys whose upstream stack or output does not exist yet,
+	// so a preview of a chain that has not been deployed treats them as unknown.
+	allowMissing bool
+
+	mu      sync.Mutex
+	outputs map[string]auto.OutputMap
+}
+
+func newOutputResolver(org string, projects []proj.Project, allowMissing bool) *outputResolver {
+	return &outputResolver{
+		org:          org,
+		projects:     projects,
+		allowMissing: allowMissing,
+		outputs:      make(map[string]auto.OutputMap),
+	}
+}
+
+// store caches the outputs of a stack that has just been deployed.
+func (r *outputResolver) store(vertex string, outputs auto.OutputMap) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outputs[vertex] = outputs
+}
+
+// stackOutputs returns the outputs for an upstream vertex, reading them from the stack if
+// they are not cached. The upstream stack is selected with its own env, so backend
+// credentials, passphrases and AWS profiles apply just as they do when it is run. It is
+// never created: a missing upstream stack is an error wrapping errNotFound.
+func (r *outputResolver) stackOutputs(ctx context.Context, vertex string, run stackRun) (auto.OutputMap, error) {
+	r.mu.Lock()
+	outputs, ok := r.outputs[vertex]
+	r.mu.Unlock()
+	if ok {
+		return outputs, nil
+	}
+
+	upstream := run
+	upstream.vertex = vertex
+	upstream.project, upstream.stack = findProject(r.projects, vertex)
+	upstream.env = run.envs[vertex]
+	upstream.logger = run.logger.With(zap.String("upstream", vertex))
+	s, err := selectStack(ctx, r.org, upstream.stack, upstream.project, upstream.source, stackEnv(upstream))
+	if auto.IsSelectStack404Error(err) {
+		return nil, fmt.Errorf("upstream stack %s %w", vertex, errNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to select upstream stack %s: %w", vertex, err)
+	}
+	outputs, err = s.Outputs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outputs of %s: %w", vertex, err)
+	}
+
+	r.store(vertex, outputs)
+	return outputs, nil
+}
+
+// apply sets every configFrom key for the stack being run from its upstream outputs.
+func (r *outputResolver) apply(ctx context.Context, s auto.Stack, run stackRun) error {
+	sc, _ := run.project.Stack(run.stack)
+	for _, ref := range sc.ConfigFrom {
+		depProject, depStack := ref.Source(run.stack)
+		upstream := depProject + ":" + depStack
+
+		outputs, err := r.stackOutputs(ctx, upstream, run)
+		var value string
+		var secret bool
+		if err == nil {
+			if value, secret, err = lookupOutput(outputs, ref.Output); err != nil {
+				err = fmt.Errorf("failed to set config %q from %s: %w", ref.Key, upstream, err)
+			}
+		}
+		if err != nil && r.allowMissing && errors.Is(err, errNotFound) {
+			run.logger.Warn("Upstream output does not exist yet, leaving config unset",
+				zap.String("key", ref.Key),
+				zap.String("upstream", upstream),
+				zap.String("output", ref.Output),
+				zap.Error(err),
+			)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.SetConfig(ctx, ref.Key, auto.ConfigValue{Value: value, Secret: secret || ref.Secret}); err != nil {
+			return fmt.Errorf("failed to set config %q: %w", ref.Key, err)
+		}
+		run.logger.Info("Set config from upstream output",
+			zap.String("key", ref.Key),
+			zap.String("upstream", upstream),
+			zap.String("output", ref.Output),
+		)
+	}
+	return nil
+}
+
+// lookupOutput finds an output by name, using dots to select values nested in map outputs.
+// Non-string values are encoded as JSON.
+func lookupOutput(outputs auto.OutputMap, path string) (string, bool, error) {
+	// Prefer an output whose name matches the whole path
+	output, ok := outputs[path]
+	value := output.Value
+	if !ok {
+		name, rest, _ := strings.Cut(path, ".")
+		output, ok = outputs[name]
+		if !ok {
+			return "", false, fmt.Errorf("output %q %w", name, errNotFound)
+		}
+		value = output.Value
+		for _, key := range strings.Split(rest, ".") {
+			nested, isMap := value.(map[string]interface{})
+			if !isMap {
+				return "", false, fmt.Errorf("output %q has no value at %q", name, path)
+			}
+			if value, ok = nested[key]; !ok {
+				return "", false, fmt.Errorf("output %q has no value at %q", name, path)
+			}
+		}
+	}
+
+	if str, isString := value.(string); isString {
+		return str, output.Secret, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to encode output %q: %w", path, err)
+	}
+	return string(encoded), output.Secret, nil
+}
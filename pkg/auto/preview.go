@@ -0,0 +1,104 @@
+// pkg/auto/preview.go - Preview Pulumi stacks across the whole dependency graph
+package auto
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	proj "github.com/jaxxstorm/pedloy/pkg/project"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optpreview"
+	"go.uber.org/zap"
+)
+
+// summaryColumns are the change types shown in the change summary, in display order.
+var summaryColumns = []string{"create", "update", "delete", "replace", "same"}
+
+func previewStack(ctx context.Context, run stackRun, org string, jsonLog bool, outputs *outputResolver) (map[string]int, error) {
+	logger := run.logger
+
+	s, err := selectRunStack(ctx, org, run)
+	if err != nil {
+		logger.Error("Failed to create or select stack", zap.Error(err))
+		return nil, err
+	}
+	if err := applyStackConfig(ctx, s, run); err != nil {
+		logger.Error("Failed to set stack config", zap.Error(err))
+		return nil, err
+	}
+	if err := outputs.apply(ctx, s, run); err != nil {
+		logger.Error("Failed to set config from upstream outputs", zap.Error(err))
+		return nil, err
+	}
+	logger.Info("Previewing stack")
+
+	var res auto.PreviewResult
+	if jsonLog {
+		eventChannel := make(chan events.EngineEvent)
+		go processEvents(logger, eventChannel)
+		res, err = s.Preview(ctx, optpreview.EventStreams(eventChannel))
+	} else {
+		out, flush := run.redact.Writer(os.Stdout)
+		res, err = s.Preview(ctx, optpreview.ProgressStreams(out))
+		flush()
+	}
+	if err != nil {
+		logger.Error("Failed to preview stack", zap.Error(err))
+		return nil, err
+	}
+
+	changes := make(map[string]int, len(res.ChangeSummary))
+	for op, count := range res.ChangeSummary {
+		changes[string(op)] = count
+	}
+	logger.Info("Successfully previewed stack", zap.Any("changes", changes))
+	return changes, nil
+}
+
+// Preview runs `pulumi preview` against every stack in dependency order. Stacks downstream
+// of a failed preview are skipped. The returned error is nil only if every preview succeeded.
+func Preview(ctx context.Context, projects []proj.Project, opts Options) (*Result, error) {
+	// Create a logger with a global field for previews
+	logger := createOutputLogger(zap.String("operation", "preview"))
+	defer logger.Sync()
+
+	op := operation{name: "preview", noun: "Preview"}
+	outputs := newOutputResolver(opts.Org, projects, true)
+	return walk(ctx, logger, op, projects, opts, func(ctx context.Context, run stackRun) (map[string]int, error) {
+		return previewStack(ctx, run, opts.Org, opts.JSONLogger, outputs)
+	})
+}
+
+// WriteChangeSummary writes a table of the resource changes reported for every stack,
+// followed by the totals across the whole graph.
+func WriteChangeSummary(w io.Writer, result *Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprint(tw, "STACK\tSTATUS")
+	for _, column := range summaryColumns {
+		fmt.Fprintf(tw, "\t%s", column)
+	}
+	fmt.Fprintln(tw)
+
+	totals := make(map[string]int)
+	for _, v := range result.Vertices {
+		fmt.Fprintf(tw, "%s\t%s", v.Vertex, v.Status)
+		for _, column := range summaryColumns {
+			fmt.Fprintf(tw, "\t%d", v.Changes[column])
+			totals[column] += v.Changes[column]
+		}
+		fmt.Fprintln(tw)
+	}
+
+	fmt.Fprint(tw, "TOTAL\t")
+	for _, column := range summaryColumns {
+		fmt.Fprintf(tw, "\t%d", totals[column])
+	}
+	fmt.Fprintln(tw)
+
+	return tw.Flush()
+}
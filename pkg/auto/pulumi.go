@@ -7,12 +7,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/jaxxstorm/pedloy/pkg/graph"
 	proj "github.com/jaxxstorm/pedloy/pkg/project"
+	src "github.com/jaxxstorm/pedloy/pkg/source"
 	"github.com/pulumi/pulumi/sdk/v3/go/auto"
 	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
 	"github.com/pulumi/pulumi/sdk/v3/go/auto/optdestroy"
@@ -22,12 +22,17 @@ import (
 )
 
 func createOutputLogger(fields ...zap.Field) *zap.Logger {
+	return newOutputLogger(os.Stdout, fields...)
+}
+
+// newOutputLogger creates the console logger used by createOutputLogger, writing to w.
+func newOutputLogger(w zapcore.WriteSyncer, fields ...zap.Field) *zap.Logger {
 	encoderConfig := zap.NewDevelopmentEncoderConfig()
 	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 	consoleEncoder := zapcore.NewConsoleEncoder(encoderConfig)
 
-	core := zapcore.NewCore(consoleEncoder, zapcore.Lock(os.Stdout), zapcore.DebugLevel)
+	core := zapcore.NewCore(consoleEncoder, zapcore.Lock(w), zapcore.DebugLevel)
 
 	sampling := zapcore.NewSamplerWithOptions(
 		core,
@@ -51,7 +56,30 @@ func processEvents(logger *zap.Logger, eventChannel <-chan events.EngineEvent) {
 	}
 }
 
-func createOrSelectStack(ctx context.Context, org string, stackName string, project proj.Project, source proj.ProjectSource) (auto.Stack, error) {
+// createOrSelectStack creates or selects the stack in a workspace of its own. The env vars
+// are scoped to that workspace and passed to every Pulumi process it starts, so stacks
+// running concurrently never see each other's environment.
+func createOrSelectStack(ctx context.Context, org string, stackName string, project proj.Project, source proj.ProjectSource, env map[string]string) (auto.Stack, error) {
+	name, projectPath, opts, err := stackWorkspace(org, stackName, project, source, env)
+	if err != nil {
+		return auto.Stack{}, err
+	}
+	return auto.UpsertStackLocalSource(ctx, name, projectPath, opts...)
+}
+
+// selectStack selects an existing stack like createOrSelectStack, but fails instead of
+// creating the stack if it does not exist.
+func selectStack(ctx context.Context, org string, stackName string, project proj.Project, source proj.ProjectSource, env map[string]string) (auto.Stack, error) {
+	name, projectPath, opts, err := stackWorkspace(org, stackName, project, source, env)
+	if err != nil {
+		return auto.Stack{}, err
+	}
+	return auto.SelectStackLocalSource(ctx, name, projectPath, opts...)
+}
+
+// stackWorkspace returns the fully qualified stack name, the project directory and the
+// workspace options for a stack.
+func stackWorkspace(org string, stackName string, project proj.Project, source proj.ProjectSource, env map[string]string) (string, string, []auto.LocalWorkspaceOption, error) {
 	var usedStackName string
 	if org == "" {
 		usedStackName = stackName
@@ -61,353 +89,278 @@ func createOrSelectStack(ctx context.Context, org string, stackName string, proj
 	projectPath := source.LocalPath
 	if project.Dir != "" {
 		projectPath = project.Dir
+		// Project directories are relative to the repository root for Git sources
+		if source.IsGit && !filepath.IsAbs(project.Dir) {
+			projectPath = filepath.Join(source.CheckoutDir, project.Dir)
+		}
 	} else if source.LocalPath != "" {
 		projectPath = filepath.Join(source.LocalPath, project.Name)
 	} else {
 		projectPath = project.Name
 	}
-	return auto.UpsertStackLocalSource(ctx, usedStackName, projectPath)
-}
-
-func deployStack(project proj.Project, stack string, org string, source proj.ProjectSource, ctx context.Context, logger *zap.Logger, jsonLog bool) error {
-	// Set environment variables for this stack if present
-	var envVars map[string]string
-	for _, sc := range project.Stacks {
-		if sc.Name == stack && sc.Env != nil {
-			envVars = sc.Env
-			break
+	if source.IsGit {
+		if _, err := os.Stat(projectPath); err != nil {
+			return "", "", nil, fmt.Errorf("project %s not found in %s: %w", project.Name, source.GitURL, err)
 		}
 	}
-	s, err := createOrSelectStack(ctx, org, stack, project, source)
-	if err != nil {
-		logger.Error("Failed to create or select stack", zap.Error(err))
-		return err
+	var opts []auto.LocalWorkspaceOption
+	if len(env) > 0 {
+		opts = append(opts, auto.EnvVars(env))
 	}
-	var ws auto.Workspace
-	if len(envVars) > 0 {
-		ws = s.Workspace()
-		for k, v := range envVars {
-			ws.SetEnvVar(k, v)
-		}
-		logger.Info("Setting environment variables for stack",
-			zap.String("project", project.Name),
-			zap.String("stack", stack),
-			zap.Any("env_vars", envVars),
-		)
-	} else {
-		logger.Info("No stack-specific env vars set for stack",
-			zap.String("project", project.Name),
-			zap.String("stack", stack),
-		)
-	}
-	logger = logger.With(zap.String("project", project.Name), zap.String("stack", stack))
-	logger.Info("Deploying stack")
+	return usedStackName, projectPath, opts, nil
+}
 
-	eventChannel := make(chan events.EngineEvent)
-	go processEvents(logger, eventChannel)
+// selectRunStack creates or selects the stack for a run with its complete environment.
+func selectRunStack(ctx context.Context, org string, run stackRun) (auto.Stack, error) {
+	return createOrSelectStack(ctx, org, run.stack, run.project, run.source, stackEnv(run))
+}
 
-	var upErr error
-	if jsonLog {
-		_, upErr = s.Up(ctx, optup.EventStreams(eventChannel))
-	} else {
-		_, upErr = s.Up(ctx, optup.ProgressStreams(os.Stdout))
+// selectExistingRunStack selects the stack for a run like selectRunStack, but fails
+// instead of creating the stack if it does not exist.
+func selectExistingRunStack(ctx context.Context, org string, run stackRun) (auto.Stack, error) {
+	s, err := selectStack(ctx, org, run.stack, run.project, run.source, stackEnv(run))
+	if auto.IsSelectStack404Error(err) {
+		return auto.Stack{}, fmt.Errorf("stack %s does not exist: %w", run.vertex, err)
 	}
-	if upErr != nil {
-		logger.Error("Failed to deploy stack", zap.Error(upErr))
-	} else {
-		logger.Info("Successfully deployed stack")
-	}
-	// Unset env vars after stack operation
-	if envVars != nil && ws != nil {
-		for k := range envVars {
-			ws.UnsetEnvVar(k)
-		}
-		logger.Info("Unset environment variables for stack",
-			zap.String("project", project.Name),
-			zap.String("stack", stack),
-			zap.Any("env_vars", envVars),
-		)
-	}
-	return upErr
+	return s, err
 }
 
-func Deploy(org string, projects []proj.Project, source proj.ProjectSource, jsonLogger bool, errorFile string) {
-	// Create a logger with a global field for deployment
-	logger := createOutputLogger(zap.String("operation", "deploy"))
-	defer logger.Sync()
-
-	logger.Info("Starting deployment")
-
-	// Get execution groups
-	executionGroups, err := graph.GetExecutionGroups(projects)
-	if err != nil {
-		logger.Fatal("Failed to determine execution groups", zap.Error(err))
+// prepareSource clones Git sources once per run and points the source at the checkout.
+// Local sources are returned unchanged. The returned cleanup function removes any checkout.
+func prepareSource(ctx context.Context, source proj.ProjectSource, logger *zap.Logger) (proj.ProjectSource, func(), error) {
+	if !source.IsGit {
+		return source, func() {}, nil
 	}
 
-	// Log the execution schedule
-	logger.Info("Execution Schedule")
-	for i, group := range executionGroups {
-		logger.Info("Deployment Stage",
-			zap.Int("stage", i+1),
-			zap.Strings("deployments", group))
+	revision := source.Revision()
+	if revision == "" {
+		revision = "default branch"
+	}
+	logger.Info("Cloning project source",
+		zap.String("url", source.GitURL),
+		zap.String("revision", revision),
+	)
+	dir, cleanup, err := src.Checkout(ctx, source.GitURL, source.Revision())
+	if err != nil {
+		cleanup()
+		return source, func() {}, err
 	}
 
-	ctx := context.Background()
-	deployed := make(map[string]bool)
-	mu := &sync.Mutex{}
-	var allErrors []error
-
-	// Execute each group sequentially
-	for groupIndex, group := range executionGroups {
-		stageLogger := logger.With(zap.Int("stage", groupIndex+1))
-		stageLogger.Info("Executing deployment stage")
-
-		var groupWG sync.WaitGroup
-		groupErrors := make(chan error, len(group))
-
-		// Deploy all items in the group concurrently
-		for _, vertex := range group {
-			groupWG.Add(1)
-			go func(vertex string) {
-				defer groupWG.Done()
-
-				// Parse project and stack from vertex ID
-				parts := strings.Split(vertex, ":")
-				projectName, stackName := parts[0], parts[1]
-
-				// Find the project definition
-				var projectDef proj.Project
-				for _, p := range projects {
-					if p.Name == projectName {
-						projectDef = p
-						break
-					}
-				}
-
-				// Deploy the stack
-				stackLogger := stageLogger.With(
-					zap.String("project", projectName),
-					zap.String("stack", stackName),
-				)
-				stackLogger.Info("Deploying stack")
-				err := deployStack(projectDef, stackName, org, source, ctx, stackLogger, jsonLogger)
-				if err != nil {
-					// Log error to file if errorFile is set
-					if errorFile != "" {
-						f, ferr := os.OpenFile(errorFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-						if ferr == nil {
-							defer f.Close()
-							f.WriteString(fmt.Sprintf("failed to deploy %s: %v\n", vertex, err))
-						}
-					}
-					groupErrors <- fmt.Errorf("failed to deploy %s: %w", vertex, err)
-					return
-				}
+	source.CheckoutDir = dir
+	// A local path is treated as a subdirectory of the repository
+	source.LocalPath = filepath.Join(dir, source.LocalPath)
+	return source, cleanup, nil
+}
 
-				// Mark as deployed
-				mu.Lock()
-				deployed[vertex] = true
-				mu.Unlock()
-			}(vertex)
-		}
+// stackEnv builds the complete set of environment variables for the stack's Pulumi
+// processes. A new map is built for every call, so nothing is shared between stacks.
+func stackEnv(run stackRun) map[string]string {
+	logger := run.logger
 
-		// Wait for all deployments in this group to complete
-		groupWG.Wait()
-		close(groupErrors)
+	envVars := make(map[string]string)
+	for k, v := range run.env {
+		envVars[k] = v.Value
+	}
+	if profile := envVars["AWS_PROFILE"]; profile != "" {
+		logger.Info("Using AWS profile", zap.String("aws_profile", profile))
+	}
+	if len(envVars) == 0 {
+		logger.Info("No stack-specific env vars set for stack")
+		return nil
+	}
 
-		// Check for any errors in this group
-		for err := range groupErrors {
-			if err != nil {
-				stageLogger.Error("Deployment failed", zap.Error(err))
-				allErrors = append(allErrors, err)
-			}
-		}
+	keys, values := envLogFields(envVars, run)
+	logger.Info("Setting environment variables for stack", keys, values)
+	return envVars
+}
 
-		stageLogger.Info("Completed deployment stage")
+// envLogFields returns log fields for a stack's env vars: every key, and the values of only
+// the allowlisted keys that are not marked secret. All other values are left out of logs.
+func envLogFields(envVars map[string]string, run stackRun) (zap.Field, zap.Field) {
+	keys := make([]string, 0, len(envVars))
+	for k := range envVars {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 
-	if len(allErrors) > 0 {
-		logger.Error("Deployment completed with errors")
-		for _, err := range allErrors {
-			logger.Error("Resource issue", zap.Error(err))
+	values := make(map[string]string)
+	for _, k := range keys {
+		if run.logEnvValues[k] && !run.env[k].Secret {
+			values[k] = envVars[k]
 		}
-	} else {
-		logger.Info("Deployment completed successfully")
 	}
+	return zap.Strings("env_keys", keys), zap.Any("env_values", values)
 }
 
-func Destroy(org string, projects []proj.Project, source proj.ProjectSource, jsonLogger bool, removeStack bool) {
-	// Create a logger with a global field for destruction
-	logger := createOutputLogger(zap.String("operation", "destroy"))
-	defer logger.Sync()
+// applyStackConfig sets the config declared in projects.yml on the stack.
+func applyStackConfig(ctx context.Context, s auto.Stack, run stackRun) error {
+	values := run.config
+	if len(values) == 0 {
+		return nil
+	}
 
-	logger.Info("Starting destruction")
+	cfg := make(auto.ConfigMap, len(values))
+	keys := make([]string, 0, len(values))
+	for k, v := range values {
+		cfg[k] = auto.ConfigValue{Value: v.Value, Secret: v.Secret}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 
-	// Get execution groups
-	executionGroups, err := graph.GetExecutionGroups(projects)
-	if err != nil {
-		logger.Fatal("Failed to determine execution groups", zap.Error(err))
+	if err := s.SetAllConfig(ctx, cfg); err != nil {
+		return fmt.Errorf("failed to set stack config: %w", err)
 	}
+	run.logger.Info("Set stack config", zap.Strings("keys", keys))
+	return nil
+}
 
-	// Log the destruction schedule in reverse order
-	logger.Info("Destruction Schedule")
-	for i := len(executionGroups) - 1; i >= 0; i-- {
-		logger.Info("Destruction Stage",
-			zap.Int("stage", len(executionGroups)-i),
-			zap.Strings("stacks", executionGroups[i]))
+// resourceChanges flattens the resource change counts from an update summary.
+func resourceChanges(summary auto.UpdateSummary) map[string]int {
+	if summary.ResourceChanges == nil {
+		return nil
 	}
+	return *summary.ResourceChanges
+}
 
-	ctx := context.Background()
-	destroyed := make(map[string]bool)
-	mu := &sync.Mutex{}
-	var allErrors []error
-
-	// Execute each group sequentially in reverse order
-	for i := len(executionGroups) - 1; i >= 0; i-- {
-		group := executionGroups[i]
-		stageLogger := logger.With(zap.Int("stage", len(executionGroups)-i))
-		stageLogger.Info("Executing destruction stage")
-
-		var groupWG sync.WaitGroup
-		groupErrors := make(chan error, len(group))
-
-		// Destroy all items in the group concurrently
-		for _, vertex := range group {
-			groupWG.Add(1)
-			go func(vertex string) {
-				defer groupWG.Done()
-
-				// Parse project and stack from vertex ID
-				parts := strings.Split(vertex, ":")
-				projectName, stackName := parts[0], parts[1]
-
-				// Find the project definition
-				var projectDef proj.Project
-				for _, p := range projects {
-					if p.Name == projectName {
-						projectDef = p
-						break
-					}
-				}
+func deployStack(ctx context.Context, run stackRun, org string, jsonLog bool, outputs *outputResolver) (map[string]int, error) {
+	logger := run.logger
 
-				// Create event channel for this stack
-				eventChannel := make(chan events.EngineEvent)
-				go processEvents(stageLogger.With(
-					zap.String("project", projectName),
-					zap.String("stack", stackName),
-				), eventChannel)
-
-				// Create or select the stack
-				s, err := createOrSelectStack(ctx, org, stackName, projectDef, source)
-				if err != nil {
-					groupErrors <- fmt.Errorf("failed to select stack %s: %w", vertex, err)
-					return
-				}
+	s, err := selectRunStack(ctx, org, run)
+	if err != nil {
+		logger.Error("Failed to create or select stack", zap.Error(err))
+		return nil, err
+	}
+	if err := applyStackConfig(ctx, s, run); err != nil {
+		logger.Error("Failed to set stack config", zap.Error(err))
+		return nil, err
+	}
+	if err := outputs.apply(ctx, s, run); err != nil {
+		logger.Error("Failed to set config from upstream outputs", zap.Error(err))
+		return nil, err
+	}
 
-				// Set environment variables for this stack if present
-				var envVars map[string]string
-				for _, sc := range projectDef.Stacks {
-					if sc.Name == stackName && sc.Env != nil {
-						envVars = sc.Env
-						break
-					}
-				}
-				var ws auto.Workspace
-				if len(envVars) > 0 {
-					ws = s.Workspace()
-					for k, v := range envVars {
-						ws.SetEnvVar(k, v)
-					}
-					stageLogger.Info("Setting environment variables for stack",
-						zap.String("project", projectDef.Name),
-						zap.String("stack", stackName),
-						zap.Any("env_vars", envVars),
-					)
-				} else {
-					stageLogger.Info("No stack-specific env vars set for stack",
-						zap.String("project", projectDef.Name),
-						zap.String("stack", stackName),
-					)
-				}
+	var res auto.UpResult
+	upErr := withRetries(ctx, run, "Deploy", func(attempt int) error {
+		logger.Info("Deploying stack", zap.Int("attempt", attempt))
+
+		upCtx, stop := cancelOnDone(ctx, s, logger)
+		defer stop()
+
+		var err error
+		if jsonLog {
+			eventChannel := make(chan events.EngineEvent)
+			go processEvents(logger, eventChannel)
+			res, err = s.Up(upCtx, optup.EventStreams(eventChannel))
+		} else {
+			out, flush := run.redact.Writer(os.Stdout)
+			res, err = s.Up(upCtx, optup.ProgressStreams(out))
+			flush()
+		}
+		return err
+	})
+	if upErr != nil {
+		logger.Error("Failed to deploy stack", zap.Error(upErr))
+	} else {
+		outputs.store(run.vertex, res.Outputs)
+		logger.Info("Successfully deployed stack")
+	}
+	return resourceChanges(res.Summary), upErr
+}
 
-				var destroyErr error
-				if jsonLogger {
-					_, destroyErr = s.Destroy(ctx, optdestroy.EventStreams(eventChannel))
-				} else {
-					_, destroyErr = s.Destroy(ctx, optdestroy.ProgressStreams(os.Stdout))
-				}
+func destroyStack(ctx context.Context, run stackRun, org string, jsonLog bool, removeStack bool) (map[string]int, error) {
+	logger := run.logger
 
-				// Remove stack if requested and destroy succeeded
-				var removeErr error
-				if removeStack && destroyErr == nil {
-					if err := s.Workspace().RemoveStack(ctx, s.Name()); err != nil {
-						stageLogger.Error("Failed to remove stack after destroy",
-							zap.String("project", projectDef.Name),
-							zap.String("stack", stackName),
-							zap.Error(err),
-						)
-						removeErr = fmt.Errorf("failed to remove stack %s: %w", vertex, err)
-					} else {
-						stageLogger.Info("Removed stack after destroy",
-							zap.String("project", projectDef.Name),
-							zap.String("stack", stackName),
-						)
-					}
-				}
+	// Create or select the stack
+	s, err := selectRunStack(ctx, org, run)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select stack: %w", err)
+	}
 
-				// Always unset env vars after stack operation, regardless of destroy/remove success
-				if envVars != nil && ws != nil {
-					for k := range envVars {
-						ws.UnsetEnvVar(k)
-					}
-					stageLogger.Info("Unset environment variables for stack",
-						zap.String("project", projectDef.Name),
-						zap.String("stack", stackName),
-						zap.Any("env_vars", envVars),
-					)
-				}
+	if err := applyStackConfig(ctx, s, run); err != nil {
+		return nil, err
+	}
 
-				// Report errors after cleanup
-				if destroyErr != nil {
-					groupErrors <- fmt.Errorf("failed to destroy %s: %w", vertex, destroyErr)
-					return
-				}
-				if removeErr != nil {
-					groupErrors <- removeErr
-					return
-				}
+	var res auto.DestroyResult
+	destroyErr := withRetries(ctx, run, "Destroy", func(attempt int) error {
+		logger.Info("Destroying stack", zap.Int("attempt", attempt))
+
+		destroyCtx, stop := cancelOnDone(ctx, s, logger)
+		defer stop()
+
+		var err error
+		if jsonLog {
+			eventChannel := make(chan events.EngineEvent)
+			go processEvents(logger, eventChannel)
+			res, err = s.Destroy(destroyCtx, optdestroy.EventStreams(eventChannel))
+		} else {
+			out, flush := run.redact.Writer(os.Stdout)
+			res, err = s.Destroy(destroyCtx, optdestroy.ProgressStreams(out))
+			flush()
+		}
+		return err
+	})
+
+	// Remove stack if requested and destroy succeeded
+	var removeErr error
+	if removeStack && destroyErr == nil {
+		if err := s.Workspace().RemoveStack(ctx, s.Name()); err != nil {
+			logger.Error("Failed to remove stack after destroy", zap.Error(err))
+			removeErr = fmt.Errorf("failed to remove stack: %w", err)
+		} else {
+			logger.Info("Removed stack after destroy")
+		}
+	}
 
-				// Mark as destroyed
-				mu.Lock()
-				destroyed[vertex] = true
-				mu.Unlock()
+	// Report errors after cleanup
+	if destroyErr != nil {
+		return resourceChanges(res.Summary), destroyErr
+	}
+	if removeErr != nil {
+		return resourceChanges(res.Summary), removeErr
+	}
 
-				stageLogger.Info("Successfully destroyed stack",
-					zap.String("project", projectName),
-					zap.String("stack", stackName))
-			}(vertex)
-		}
+	logger.Info("Successfully destroyed stack")
+	return resourceChanges(res.Summary), nil
+}
 
-		// Wait for all destructions in this group to complete
-		groupWG.Wait()
-		close(groupErrors)
+// Deploy deploys every stack in dependency order. The returned error is nil only if every stack succeeded.
+func Deploy(ctx context.Context, projects []proj.Project, opts Options, errorFile string) (*Result, error) {
+	// Create a logger with a global field for deployment
+	logger := createOutputLogger(zap.String("operation", "deploy"))
+	defer logger.Sync()
 
-		// Check for any errors in this group
-		for err := range groupErrors {
-			if err != nil {
-				stageLogger.Error("Destruction failed", zap.Error(err))
-				allErrors = append(allErrors, err)
+	op := operation{name: "deploy", noun: "Deployment"}
+	outputs := newOutputResolver(opts.Org, projects, false)
+	return walk(ctx, logger, op, projects, opts, func(ctx context.Context, run stackRun) (map[string]int, error) {
+		changes, err := deployStack(ctx, run, opts.Org, opts.JSONLogger, outputs)
+		err = run.redact.Error(err)
+		if err != nil && errorFile != "" {
+			// Log error to file if errorFile is set
+			f, ferr := os.OpenFile(errorFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if ferr == nil {
+				defer f.Close()
+				f.WriteString(fmt.Sprintf("failed to deploy %s: %v\n", run.vertex, err))
 			}
 		}
+		return changes, err
+	})
+}
 
-		stageLogger.Info("Completed destruction stage")
-	}
+// Destroy destroys every stack in reverse dependency order. The returned error is nil only if every stack was destroyed.
+func Destroy(ctx context.Context, projects []proj.Project, opts Options, removeStack bool) (*Result, error) {
+	// Create a logger with a global field for destruction
+	logger := createOutputLogger(zap.String("operation", "destroy"))
+	defer logger.Sync()
+
+	op := operation{name: "destroy", noun: "Destruction", reverse: true}
+	result, err := walk(ctx, logger, op, projects, opts, func(ctx context.Context, run stackRun) (map[string]int, error) {
+		return destroyStack(ctx, run, opts.Org, opts.JSONLogger, removeStack)
+	})
 
-	if len(allErrors) > 0 {
-		logger.Error("Destruction completed with errors")
+	if result != nil && len(result.WithStatus(StatusFailed)) > 0 {
 		fmt.Println("\nFailed Resources:")
-		for _, err := range allErrors {
-			errMsg := err.Error()
+		for _, v := range result.WithStatus(StatusFailed) {
+			errMsg := v.Err.Error()
 			lines := strings.Split(errMsg, "\n")
 			for _, line := range lines {
 				if strings.Contains(line, "urn:pulumi") {
@@ -416,7 +369,6 @@ func Destroy(org string, projects []proj.Project, source proj.ProjectSource, jso
 			}
 		}
 		fmt.Println("\nPlease address these issues manually.")
-	} else {
-		logger.Info("Destruction completed successfully")
 	}
+	return result, err
 }
@@ -0,0 +1,194 @@
+package auto
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	proj "github.com/jaxxstorm/pedloy/pkg/project"
+	"go.uber.org/zap"
+)
+
+// fakePulumi puts a pulumi script first on PATH that reports a recent version and, for
+// every other command, writes the environment it was started with to a file in the
+// returned directory named after its working directory.
+func fakePulumi(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake pulumi CLI is a shell script")
+	}
+
+	bin := t.TempDir()
+	dumps := t.TempDir()
+	script := `#!/bin/sh
+if [ "$1" = "version" ]; then
+	echo "v3.200.0"
+	exit 0
+fi
+env > "$PEDLOY_TEST_ENV_DIR/$(basename "$(pwd -P)").$$.env"
+`
+	if err := os.WriteFile(filepath.Join(bin, "pulumi"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("PEDLOY_TEST_ENV_DIR", dumps)
+	t.Setenv("PULUMI_AUTOMATION_API_SKIP_VERSION_CHECK", "true")
+	return dumps
+}
+
+// readEnvDumps returns the environments the fake pulumi CLI was started with for a
+// project directory.
+func readEnvDumps(t *testing.T, dumps string, project string) []map[string]string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dumps, project+".*.env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var envs []map[string]string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env := make(map[string]string)
+		for _, line := range strings.Split(string(data), "\n") {
+			if k, v, ok := strings.Cut(line, "="); ok {
+				env[k] = v
+			}
+		}
+		envs = append(envs, env)
+	}
+	return envs
+}
+
+// TestStackEnvConcurrentStacks runs many stacks at once whose env sets the same variables
+// to different values. Each stack's workspace is created through the Automation API with
+// a fake pulumi CLI, and the test checks that every Pulumi process sees only its own
+// stack's values and that the process environment is never changed.
+func TestStackEnvConcurrentStacks(t *testing.T) {
+	const stacks = 50
+	dumps := fakePulumi(t)
+	t.Setenv("PEDLOY_TEST_SHARED", "process")
+	t.Setenv("PEDLOY_TEST_REGION", "process-region")
+
+	root := t.TempDir()
+	// No project sets an AWS profile, so the AWS config files are never read
+	var projects []proj.Project
+	for i := 0; i < stacks; i++ {
+		name := fmt.Sprintf("p%d", i)
+		if err := os.Mkdir(filepath.Join(root, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		projects = append(projects, proj.Project{
+			Name:   name,
+			Stacks: proj.Stacks{{Name: "dev"}},
+			Env: proj.EnvMap{
+				"PEDLOY_TEST_SHARED": {Value: fmt.Sprintf("project-%d", i)},
+				// Reads the process env, which must still hold its original value
+				"PEDLOY_TEST_FROM_PROCESS": {Value: "${PEDLOY_TEST_REGION}"},
+			},
+			Defaults: proj.Defaults{Env: proj.EnvMap{
+				"PEDLOY_TEST_DEFAULT": {Value: "default"},
+			}},
+		})
+		projects[i].Stacks[0].Env = proj.EnvMap{
+			"PEDLOY_TEST_STACK": {Value: fmt.Sprintf("stack-%d", i)},
+			"PEDLOY_TEST_TOKEN": {Value: fmt.Sprintf("token-%d", i), Secret: true},
+		}
+	}
+	want := func(i string) map[string]string {
+		return map[string]string{
+			"PEDLOY_TEST_SHARED":       "project-" + i,
+			"PEDLOY_TEST_FROM_PROCESS": "process-region",
+			"PEDLOY_TEST_DEFAULT":      "default",
+			"PEDLOY_TEST_STACK":        "stack-" + i,
+			"PEDLOY_TEST_TOKEN":        "token-" + i,
+		}
+	}
+
+	// Hold every stack until all of them are running, so their workspaces are created concurrently
+	var started sync.WaitGroup
+	started.Add(stacks)
+	allStarted := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(allStarted)
+	}()
+
+	opts := Options{Source: proj.ProjectSource{LocalPath: root}}
+	result, err := walk(context.Background(), zap.NewNop(), operation{name: "test", noun: "Test"}, projects, opts,
+		func(ctx context.Context, run stackRun) (map[string]int, error) {
+			started.Done()
+			select {
+			case <-allStarted:
+			case <-time.After(30 * time.Second):
+				return nil, fmt.Errorf("stacks did not run concurrently")
+			}
+
+			env := stackEnv(run)
+			i := run.project.Name[1:]
+			if len(env) != len(want(i)) {
+				return nil, fmt.Errorf("%s: got env %v, want %v", run.vertex, env, want(i))
+			}
+			for k, v := range want(i) {
+				if env[k] != v {
+					return nil, fmt.Errorf("%s: %s = %q, want %q", run.vertex, k, env[k], v)
+				}
+			}
+
+			// Changing the env of one stack must not leak into the next build
+			env["PEDLOY_TEST_SHARED"] = "changed"
+			if again := stackEnv(run); again["PEDLOY_TEST_SHARED"] != "project-"+i {
+				return nil, fmt.Errorf("%s: env is shared between builds", run.vertex)
+			}
+
+			if _, err := selectRunStack(ctx, "", run); err != nil {
+				return nil, fmt.Errorf("%s: %w", run.vertex, err)
+			}
+
+			if v := os.Getenv("PEDLOY_TEST_SHARED"); v != "process" {
+				return nil, fmt.Errorf("%s: process env changed to %q", run.vertex, v)
+			}
+			if _, ok := os.LookupEnv("PEDLOY_TEST_STACK"); ok {
+				return nil, fmt.Errorf("%s: stack env leaked into the process env", run.vertex)
+			}
+			return nil, nil
+		})
+	if err != nil {
+		t.Fatalf("walk failed: %v", err)
+	}
+	if got := len(result.WithStatus(StatusSucceeded)); got != stacks {
+		t.Fatalf("%d stacks succeeded, want %d", got, stacks)
+	}
+
+	// Every Pulumi process started for a stack saw exactly that stack's values
+	for i := 0; i < stacks; i++ {
+		name := fmt.Sprintf("p%d", i)
+		envs := readEnvDumps(t, dumps, name)
+		if len(envs) == 0 {
+			t.Errorf("%s: pulumi was never run in the stack's workspace", name)
+		}
+		for _, env := range envs {
+			for k, v := range want(name[1:]) {
+				if env[k] != v {
+					t.Errorf("%s: pulumi ran with %s=%q, want %q", name, k, env[k], v)
+				}
+			}
+		}
+	}
+
+	for _, k := range []string{"PEDLOY_TEST_STACK", "PEDLOY_TEST_TOKEN", "PEDLOY_TEST_DEFAULT", "PEDLOY_TEST_FROM_PROCESS"} {
+		if v, ok := os.LookupEnv(k); ok {
+			t.Errorf("process env has %s=%q after the run", k, v)
+		}
+	}
+	if v := os.Getenv("PEDLOY_TEST_SHARED"); v != "process" {
+		t.Errorf("process env PEDLOY_TEST_SHARED = %q after the run, want %q", v, "process")
+	}
+}
@@ -0,0 +1,258 @@
+// pkg/auto/redact.go - Keep secret env values out of logs, engine output and reports
+package auto
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	proj "github.com/jaxxstorm/pedloy/pkg/project"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// redactedValue replaces secret values wherever they would be shown.
+const redactedValue = "[secret]"
+
+// redactor replaces secret values in text. A nil redactor leaves everything unchanged.
+type redactor struct {
+	replacer *strings.Replacer
+	// secrets are the secret values, longest first.
+	secrets []string
+	// multiline is set when a secret contains a newline.
+	multiline bool
+}
+
+// newRedactor returns a redactor for the secret values in the given envs, or nil if there are none.
+// Each secret is also matched in its JSON-escaped forms, as engine events and structured log
+// fields are encoded as JSON before they are redacted.
+func newRedactor(envs map[string]proj.EnvMap) *redactor {
+	seen := make(map[string]bool)
+	var secrets []string
+	for _, env := range envs {
+		for _, v := range env {
+			if !v.Secret || v.Value == "" {
+				continue
+			}
+			for _, secret := range append([]string{v.Value}, jsonEscaped(v.Value)...) {
+				if !seen[secret] {
+					seen[secret] = true
+					secrets = append(secrets, secret)
+				}
+			}
+		}
+	}
+	if len(secrets) == 0 {
+		return nil
+	}
+
+	// Replace longer secrets first so a secret containing another is removed whole
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	pairs := make([]string, 0, 2*len(secrets))
+	multiline := false
+	for _, secret := range secrets {
+		pairs = append(pairs, secret, redactedValue)
+		multiline = multiline || strings.Contains(secret, "\n")
+	}
+	return &redactor{replacer: strings.NewReplacer(pairs...), secrets: secrets, multiline: multiline}
+}
+
+// jsonEscaped returns s as it appears inside a JSON string, both with and without HTML
+// escaping. Either may equal s.
+func jsonEscaped(s string) []string {
+	var forms []string
+	for _, escapeHTML := range []bool{true, false} {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(escapeHTML)
+		if err := enc.Encode(s); err != nil {
+			continue
+		}
+		// Drop the quotes and the newline Encode adds
+		forms = append(forms, strings.TrimSuffix(strings.TrimPrefix(buf.String(), `"`), "\"\n"))
+	}
+	return forms
+}
+
+// String returns s with every secret value replaced.
+func (r *redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// Error returns err with secret values removed from its message. The original error is
+// kept in the chain, so errors.Is still matches it.
+func (r *redactor) Error(err error) error {
+	if r == nil || err == nil {
+		return err
+	}
+	return &redactedError{err: err, msg: r.String(err.Error())}
+}
+
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// Writer returns a writer that redacts secret values before writing to w, and a function
+// that writes out anything still held back. Output arrives in arbitrary chunks, so text
+// that could be the start of a secret is held until the next write shows whether it is;
+// call the flush function once the stream has ended.
+func (r *redactor) Writer(w io.Writer) (io.Writer, func()) {
+	if r == nil {
+		return w, func() {}
+	}
+	rw := &redactingWriter{w: w, r: r}
+	return rw, func() { rw.flush() }
+}
+
+type redactingWriter struct {
+	w io.Writer
+	r *redactor
+
+	mu sync.Mutex
+	// pending holds output that has not been written yet because a secret may span it.
+	pending []byte
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = append(w.pending, p...)
+	out, n := w.r.redactPrefix(w.pending)
+	w.pending = append(w.pending[:0], w.pending[n:]...)
+	if _, err := w.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *redactingWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) > 0 {
+		io.WriteString(w.w, w.r.String(string(w.pending)))
+		w.pending = nil
+	}
+}
+
+// redactPrefix redacts the part of buf that no secret can extend beyond, and returns it
+// along with the number of bytes of buf it covers. A secret can only start within the last
+// len(longest secret)-1 bytes if it continues past the end of buf, so those are held
+// back, unless a newline ends buf there and no secret spans lines.
+func (r *redactor) redactPrefix(buf []byte) ([]byte, int) {
+	safe := len(buf) - (len(r.secrets[0]) - 1)
+	if !r.multiline {
+		if i := bytes.LastIndexByte(buf, '\n'); i+1 > safe {
+			safe = i + 1
+		}
+	}
+
+	var out []byte
+	i := 0
+	for i < safe {
+		matched := false
+		for _, secret := range r.secrets {
+			if bytes.HasPrefix(buf[i:], []byte(secret)) {
+				out = append(out, redactedValue...)
+				i += len(secret)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			out = append(out, buf[i])
+			i++
+		}
+	}
+	return out, i
+}
+
+// Logger returns a logger that redacts secret values from messages and fields.
+func (r *redactor) Logger(logger *zap.Logger) *zap.Logger {
+	if r == nil {
+		return logger
+	}
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &redactingCore{Core: core, r: r}
+	}))
+}
+
+// redactingCore redacts entries before passing them to the wrapped core.
+type redactingCore struct {
+	zapcore.Core
+	r *redactor
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(c.fields(fields)), r: c.r}
+}
+
+func (c *redactingCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	// Let the wrapped core decide, so sampling still applies, but write through this core
+	if c.Core.Check(entry, nil) == nil {
+		return ce
+	}
+	return ce.AddCore(entry, c)
+}
+
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = c.r.String(entry.Message)
+	return c.Core.Write(entry, c.fields(fields))
+}
+
+// fields redacts the values of every field type that can hold text. Numeric, boolean,
+// time, duration and binary fields are passed through unchanged.
+func (c *redactingCore) fields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		switch f.Type {
+		case zapcore.StringType:
+			f.String = c.r.String(f.String)
+		case zapcore.ByteStringType:
+			if b, ok := f.Interface.([]byte); ok {
+				f = zap.String(f.Key, c.r.String(string(b)))
+			}
+		case zapcore.ErrorType:
+			if err, ok := f.Interface.(error); ok {
+				f = zap.String(f.Key, c.r.String(err.Error()))
+			}
+		case zapcore.StringerType:
+			if s, ok := f.Interface.(fmt.Stringer); ok {
+				f = zap.String(f.Key, c.r.String(s.String()))
+			}
+		case zapcore.ReflectType:
+			f = c.structured(f, f.Interface)
+		case zapcore.ArrayMarshalerType, zapcore.ObjectMarshalerType:
+			// Encode the value the way zap would, then redact it like a reflected value
+			enc := zapcore.NewMapObjectEncoder()
+			f.AddTo(enc)
+			f = c.structured(f, enc.Fields[f.Key])
+		}
+		redacted[i] = f
+	}
+	return redacted
+}
+
+// structured replaces a structured field with its redacted JSON encoding, but only if the
+// value actually contains a secret.
+func (c *redactingCore) structured(f zapcore.Field, value interface{}) zapcore.Field {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return f
+	}
+	if s := c.r.String(string(data)); s != string(data) {
+		return zap.String(f.Key, s)
+	}
+	return f
+}
@@ -0,0 +1,302 @@
+package auto
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	proj "github.com/jaxxstorm/pedloy/pkg/project"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// testRedactor returns a redactor for the given secret values.
+func testRedactor(t *testing.T, secrets ...string) *redactor {
+	t.Helper()
+	env := make(proj.EnvMap)
+	for i, s := range secrets {
+		env[fmt.Sprintf("SECRET_%d", i)] = proj.EnvValue{Value: s, Secret: true}
+	}
+	env["PLAIN"] = proj.EnvValue{Value: "plain"}
+	r := newRedactor(map[string]proj.EnvMap{"app:dev": env})
+	if r == nil {
+		t.Fatal("expected a redactor")
+	}
+	return r
+}
+
+func TestNewRedactorWithoutSecrets(t *testing.T) {
+	r := newRedactor(map[string]proj.EnvMap{"app:dev": {"PLAIN": {Value: "plain"}}})
+	if r != nil {
+		t.Fatal("expected no redactor without secrets")
+	}
+	if got := r.String("plain"); got != "plain" {
+		t.Errorf("nil redactor changed %q to %q", "plain", got)
+	}
+	var buf bytes.Buffer
+	w, flush := r.Writer(&buf)
+	if w != &buf {
+		t.Error("nil redactor wrapped the writer")
+	}
+	flush()
+}
+
+func TestRedactorString(t *testing.T) {
+	r := testRedactor(t, "hunter2", "p&ss<word>", `quo"te\`, "line1\nline2")
+
+	tests := []struct {
+		in, want string
+	}{
+		{in: "password is hunter2.", want: "password is [secret]."},
+		{in: "plain text stays", want: "plain text stays"},
+		{in: "raw p&ss<word>", want: "raw [secret]"},
+		{in: `{"msg":"p\u0026ss\u003cword\u003e"}`, want: `{"msg":"[secret]"}`},
+		{in: `{"msg":"p&ss<word>"}`, want: `{"msg":"[secret]"}`},
+		{in: `{"msg":"quo\"te\\"}`, want: `{"msg":"[secret]"}`},
+		{in: "key:\nline1\nline2\n", want: "key:\n[secret]\n"},
+		{in: `{"key":"line1\nline2"}`, want: `{"key":"[secret]"}`},
+	}
+	for _, tt := range tests {
+		if got := r.String(tt.in); got != tt.want {
+			t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedactorError(t *testing.T) {
+	r := testRedactor(t, "hunter2")
+	cause := errors.New("login with hunter2 failed")
+	err := r.Error(fmt.Errorf("deploy: %w", cause))
+	if got, want := err.Error(), "deploy: login with [secret] failed"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("redacted error lost its cause")
+	}
+	if r.Error(nil) != nil {
+		t.Error("nil error was not kept nil")
+	}
+}
+
+func TestRedactPrefix(t *testing.T) {
+	tests := []struct {
+		name     string
+		secrets  []string
+		buf      string
+		want     string
+		consumed int
+	}{
+		{
+			name:     "holds back a possible secret start",
+			secrets:  []string{"secret"},
+			buf:      "token: sec",
+			want:     "token",
+			consumed: 5,
+		},
+		{
+			name:     "redacts a whole secret",
+			secrets:  []string{"secret"},
+			buf:      "a secret and more text",
+			want:     "a [secret] and more",
+			consumed: 17,
+		},
+		{
+			name:     "writes up to a newline when no secret spans lines",
+			secrets:  []string{"secret"},
+			buf:      "line\nse",
+			want:     "line\n",
+			consumed: 5,
+		},
+		{
+			name:     "holds back past a newline when a secret spans lines",
+			secrets:  []string{"BEGIN\nEND"},
+			buf:      "abc\nBEGIN\n",
+			want:     "a",
+			consumed: 1,
+		},
+		{
+			name:     "buffer shorter than the longest secret",
+			secrets:  []string{"secret"},
+			buf:      "abc",
+			want:     "",
+			consumed: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testRedactor(t, tt.secrets...)
+			out, n := r.redactPrefix([]byte(tt.buf))
+			if string(out) != tt.want || n != tt.consumed {
+				t.Errorf("redactPrefix(%q) = %q, %d, want %q, %d", tt.buf, out, n, tt.want, tt.consumed)
+			}
+		})
+	}
+}
+
+// TestRedactingWriterChunks writes each input in chunks of every size and checks the
+// output matches redacting the whole input at once.
+func TestRedactingWriterChunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets []string
+		in      string
+	}{
+		{
+			name:    "secret split across writes",
+			secrets: []string{"hunter2"},
+			in:      "Updating (dev):\n  password: hunter2\n  again hunter2hunter2 end\n",
+		},
+		{
+			name:    "overlapping secrets",
+			secrets: []string{"abcdef", "cdefgh", "abc"},
+			in:      "xx abcdefgh yy cdefgh abc abcde abcdef\n",
+		},
+		{
+			name:    "multiline secret",
+			secrets: []string{"-----BEGIN KEY-----\nMIIE\n-----END KEY-----"},
+			in:      "key:\n-----BEGIN KEY-----\nMIIE\n-----END KEY-----\ndone\n-----BEGIN KEY-----\nnot it\n",
+		},
+		{
+			name:    "json-escaped secret",
+			secrets: []string{"p&ss<word>"},
+			in:      `{"diag":"p\u0026ss\u003cword\u003e"}` + "\n",
+		},
+		{
+			name:    "secret at the end of the stream",
+			secrets: []string{"hunter2"},
+			in:      "password: hunter2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testRedactor(t, tt.secrets...)
+			want := r.String(tt.in)
+			for size := 1; size <= len(tt.in); size++ {
+				var buf bytes.Buffer
+				w, flush := r.Writer(&buf)
+				for i := 0; i < len(tt.in); i += size {
+					end := i + size
+					if end > len(tt.in) {
+						end = len(tt.in)
+					}
+					n, err := w.Write([]byte(tt.in[i:end]))
+					if err != nil || n != end-i {
+						t.Fatalf("Write returned %d, %v", n, err)
+					}
+				}
+				flush()
+				if got := buf.String(); got != want {
+					t.Fatalf("chunks of %d: wrote %q, want %q", size, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactingWriterFlush(t *testing.T) {
+	r := testRedactor(t, "hunter2")
+	var buf bytes.Buffer
+	w, flush := r.Writer(&buf)
+
+	w.Write([]byte("password: hunt"))
+	if strings.Contains(buf.String(), "hunt") {
+		t.Fatalf("possible secret start was written before it was known: %q", buf.String())
+	}
+	w.Write([]byte("er2"))
+	flush()
+	if got, want := buf.String(), "password: [secret]"; got != want {
+		t.Errorf("wrote %q, want %q", got, want)
+	}
+
+	// Text held back that turns out not to be a secret is written as-is on flush
+	buf.Reset()
+	w, flush = r.Writer(&buf)
+	w.Write([]byte("almost hunter"))
+	flush()
+	if got, want := buf.String(), "almost hunter"; got != want {
+		t.Errorf("wrote %q, want %q", got, want)
+	}
+
+	// Flushing twice writes nothing more
+	flush()
+	if got, want := buf.String(), "almost hunter"; got != want {
+		t.Errorf("second flush wrote more: %q", got)
+	}
+}
+
+type stringer string
+
+func (s stringer) String() string { return string(s) }
+
+// TestRedactingLogger logs a secret through every field type that can hold text with a
+// JSON encoder, which escapes it, and checks it never reaches the output in any form.
+func TestRedactingLogger(t *testing.T) {
+	const secret = "p&ss<word>"
+	r := testRedactor(t, secret)
+
+	var buf bytes.Buffer
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&buf), zapcore.DebugLevel)
+	logger := r.Logger(zap.New(core)).With(zap.String("with", secret))
+
+	logger.Info("message "+secret,
+		zap.String("string", secret),
+		zap.ByteString("bytes", []byte(secret)),
+		zap.Error(errors.New("failed with "+secret)),
+		zap.Stringer("stringer", stringer(secret)),
+		zap.Any("reflect", map[string]string{"token": secret}),
+		zap.Strings("array", []string{"a", secret}),
+		zap.Object("object", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddString("token", secret)
+			return nil
+		})),
+		zap.Int("count", 3),
+	)
+
+	out := buf.String()
+	for _, form := range append([]string{secret}, jsonEscaped(secret)...) {
+		if strings.Contains(out, form) {
+			t.Errorf("log output contains the secret as %q:\n%s", form, out)
+		}
+	}
+	for _, key := range []string{"with", "string", "bytes", "error", "stringer", "reflect", "array", "object"} {
+		if !strings.Contains(out, `"`+key+`":`) {
+			t.Errorf("log output is missing field %s:\n%s", key, out)
+		}
+	}
+	if got := strings.Count(out, redactedValue); got != 9 {
+		t.Errorf("log output has %d redacted values, want 9:\n%s", got, out)
+	}
+	if !strings.Contains(out, `"count":3`) {
+		t.Errorf("numeric field changed:\n%s", out)
+	}
+}
+
+func TestProcessEventsRedacts(t *testing.T) {
+	const secret = "p&ss<word>"
+	r := testRedactor(t, secret)
+
+	var buf bytes.Buffer
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&buf), zapcore.DebugLevel)
+	logger := r.Logger(zap.New(core))
+
+	ch := make(chan events.EngineEvent, 1)
+	ch <- events.EngineEvent{EngineEvent: apitype.EngineEvent{
+		DiagnosticEvent: &apitype.DiagnosticEvent{Message: "bad password " + secret, Severity: "error"},
+	}}
+	close(ch)
+	processEvents(logger, ch)
+
+	out := buf.String()
+	for _, form := range append([]string{secret}, jsonEscaped(secret)...) {
+		if strings.Contains(out, form) {
+			t.Errorf("event log contains the secret as %q:\n%s", form, out)
+		}
+	}
+	if !strings.Contains(out, "bad password "+redactedValue) {
+		t.Errorf("event log is missing the redacted message:\n%s", out)
+	}
+}
@@ -0,0 +1,78 @@
+// pkg/auto/refresh.go - Refresh Pulumi stacks across the whole dependency graph
+package auto
+
+import (
+	"context"
+	"os"
+
+	proj "github.com/jaxxstorm/pedloy/pkg/project"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optrefresh"
+	"go.uber.org/zap"
+)
+
+// refreshStack refreshes an existing stack and returns the changes the refresh made to its
+// state. A stack that does not exist is an error rather than being created.
+func refreshStack(ctx context.Context, run stackRun, org string, jsonLog bool) (map[string]int, error) {
+	logger := run.logger
+
+	s, err := selectExistingRunStack(ctx, org, run)
+	if err != nil {
+		logger.Error("Failed to select stack", zap.Error(err))
+		return nil, err
+	}
+	if err := applyStackConfig(ctx, s, run); err != nil {
+		logger.Error("Failed to set stack config", zap.Error(err))
+		return nil, err
+	}
+	logger.Info("Refreshing stack")
+
+	refreshCtx, stop := cancelOnDone(ctx, s, logger)
+	defer stop()
+
+	var res auto.RefreshResult
+	if jsonLog {
+		eventChannel := make(chan events.EngineEvent)
+		go processEvents(logger, eventChannel)
+		res, err = s.Refresh(refreshCtx, optrefresh.EventStreams(eventChannel))
+	} else {
+		out, flush := run.redact.Writer(os.Stdout)
+		res, err = s.Refresh(refreshCtx, optrefresh.ProgressStreams(out))
+		flush()
+	}
+	if err != nil {
+		logger.Error("Failed to refresh stack", zap.Error(err))
+		return nil, err
+	}
+
+	changes := resourceChanges(res.Summary)
+	if hasChanges(changes) {
+		logger.Warn("Drift detected in stack", zap.Any("changes", changes))
+	} else {
+		logger.Info("Successfully refreshed stack, no drift detected")
+	}
+	return changes, nil
+}
+
+// Refresh runs `pulumi refresh` against every stack in dependency order, reconciling
+// state with the real infrastructure. Stacks whose state changed are reported as drifted.
+func Refresh(ctx context.Context, projects []proj.Project, opts Options) (*Result, error) {
+	// Create a logger with a global field for refreshes
+	logger := createOutputLogger(zap.String("operation", "refresh"))
+	defer logger.Sync()
+
+	op := operation{name: "refresh", noun: "Refresh"}
+	result, err := walk(ctx, logger, op, projects, opts, func(ctx context.Context, run stackRun) (map[string]int, error) {
+		return refreshStack(ctx, run, opts.Org, opts.JSONLogger)
+	})
+
+	if result != nil {
+		var drifted []string
+		for _, v := range result.Drifted() {
+			drifted = append(drifted, v.Vertex)
+		}
+		logger.Info("Drift Summary", zap.Strings("drifted", drifted))
+	}
+	return result, err
+}
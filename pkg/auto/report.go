@@ -0,0 +1,265 @@
+// pkg/auto/report.go - Write a run summary report as JSON, Markdown or JUnit XML
+package auto
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jaxxstorm/pedloy/pkg/config"
+)
+
+// ReportFormats lists the supported report formats.
+var ReportFormats = []string{"json", "markdown", "junit"}
+
+// ValidateReportFormat returns an error wrapping config.ErrInvalidConfig if format is not
+// a supported report format.
+func ValidateReportFormat(format string) error {
+	for _, f := range ReportFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unsupported report format %q, expected one of: %s", config.ErrInvalidConfig, format, strings.Join(ReportFormats, ", "))
+}
+
+// WriteReport writes a summary of the run in the given format.
+func WriteReport(w io.Writer, result *Result, format string) error {
+	switch format {
+	case "json":
+		return writeJSONReport(w, result)
+	case "markdown":
+		return writeMarkdownReport(w, result)
+	case "junit":
+		return writeJUnitReport(w, result)
+	default:
+		return ValidateReportFormat(format)
+	}
+}
+
+// WriteReportFile writes the report to a file, replacing it if it exists.
+func WriteReportFile(path string, result *Result, format string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	if err := WriteReport(f, result, format); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return f.Close()
+}
+
+// succeeded reports whether every vertex in the result succeeded.
+func (r *Result) succeeded() bool {
+	return len(r.WithStatus(StatusSucceeded)) == len(r.Vertices)
+}
+
+// formatChanges renders change counts as "create=1 update=2", in a stable order.
+func formatChanges(changes map[string]int) string {
+	ops := make([]string, 0, len(changes))
+	for op := range changes {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	parts := make([]string, 0, len(ops))
+	for _, op := range ops {
+		parts = append(parts, fmt.Sprintf("%s=%d", op, changes[op]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// jsonReportStack is a vertex in the JSON report.
+type jsonReportStack struct {
+	Vertex          string         `json:"vertex"`
+	Project         string         `json:"project"`
+	Stack           string         `json:"stack"`
+	Stage           int            `json:"stage"`
+	Status          Status         `json:"status"`
+	DurationSeconds float64        `json:"durationSeconds"`
+	Changes         map[string]int `json:"changes,omitempty"`
+	Upstream        string         `json:"upstream,omitempty"`
+	Error           string         `json:"error,omitempty"`
+}
+
+func writeJSONReport(w io.Writer, result *Result) error {
+	report := struct {
+		Operation       string            `json:"operation"`
+		Succeeded       bool              `json:"succeeded"`
+		DurationSeconds float64           `json:"durationSeconds"`
+		Stacks          []jsonReportStack `json:"stacks"`
+	}{
+		Operation:       result.Operation,
+		Succeeded:       result.succeeded(),
+		DurationSeconds: result.Duration.Seconds(),
+		Stacks:          []jsonReportStack{},
+	}
+	for _, v := range result.Vertices {
+		stack := jsonReportStack{
+			Vertex:          v.Vertex,
+			Project:         v.Project,
+			Stack:           v.Stack,
+			Stage:           v.Stage,
+			Status:          v.Status,
+			DurationSeconds: v.Duration.Seconds(),
+			Changes:         v.Changes,
+			Upstream:        v.Upstream,
+		}
+		if v.Err != nil {
+			stack.Error = v.Err.Error()
+		}
+		report.Stacks = append(report.Stacks, stack)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(report)
+}
+
+func writeMarkdownReport(w io.Writer, result *Result) error {
+	// Table cells cannot contain pipes or line breaks
+	cell := func(s string) string {
+		s = strings.ReplaceAll(s, "|", "\\|")
+		return strings.Join(strings.Fields(s), " ")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# pedloy %s report\n\n", result.Operation)
+	counts := make(map[Status]int)
+	for _, v := range result.Vertices {
+		counts[v.Status]++
+	}
+	var summary []string
+	for _, status := range []Status{StatusSucceeded, StatusFailed, StatusTimedOut, StatusSkipped, StatusInterrupted, StatusCancelled} {
+		if counts[status] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+	fmt.Fprintf(&b, "%d stacks in %s: %s.\n\n", len(result.Vertices), result.Duration.Round(time.Second), strings.Join(summary, ", "))
+
+	b.WriteString("| Stack | Stage | Status | Duration | Changes | Error |\n")
+	b.WriteString("|-------|-------|--------|----------|---------|-------|\n")
+	for _, v := range result.Vertices {
+		errText := ""
+		switch {
+		case v.Err != nil:
+			errText, _, _ = strings.Cut(v.Err.Error(), "\n")
+		case v.Upstream != "":
+			errText = "upstream " + v.Upstream + " failed"
+		}
+		fmt.Fprintf(&b, "| %s | %d | %s | %s | %s | %s |\n",
+			v.Vertex, v.Stage, v.Status, v.Duration.Round(time.Second), formatChanges(v.Changes), cell(errText))
+	}
+
+	// Full error output is often multi-line, so list it separately
+	first := true
+	for _, v := range result.Vertices {
+		if v.Err == nil {
+			continue
+		}
+		if first {
+			b.WriteString("\n## Errors\n")
+			first = false
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n```\n%s\n```\n", v.Vertex, strings.TrimSpace(v.Err.Error()))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// junitTestSuites is the root element of a JUnit XML report.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Name    string           `xml:"name,attr"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnitReport writes one test case per stack. Failed and timed out stacks are
+// failures, interrupted stacks are errors, and skipped or cancelled stacks are skipped.
+func writeJUnitReport(w io.Writer, result *Result) error {
+	seconds := func(d time.Duration) string {
+		return fmt.Sprintf("%.3f", d.Seconds())
+	}
+
+	suite := junitTestSuite{
+		Name:  result.Operation,
+		Tests: len(result.Vertices),
+		Time:  seconds(result.Duration),
+	}
+	for _, v := range result.Vertices {
+		tc := junitTestCase{
+			Name:      v.Vertex,
+			ClassName: v.Project,
+			Time:      seconds(v.Duration),
+		}
+		if len(v.Changes) > 0 {
+			tc.SystemOut = formatChanges(v.Changes)
+		}
+		errText := ""
+		if v.Err != nil {
+			errText = v.Err.Error()
+		}
+		firstLine, _, _ := strings.Cut(errText, "\n")
+
+		switch v.Status {
+		case StatusFailed, StatusTimedOut:
+			tc.Failure = &junitMessage{Message: firstLine, Type: string(v.Status), Text: errText}
+			suite.Failures++
+		case StatusInterrupted:
+			tc.Error = &junitMessage{Message: firstLine, Type: string(v.Status), Text: errText}
+			suite.Errors++
+		case StatusSkipped:
+			tc.Skipped = &junitMessage{Message: "upstream " + v.Upstream + " failed"}
+			suite.Skipped++
+		case StatusCancelled:
+			tc.Skipped = &junitMessage{Message: "run cancelled before the stack started"}
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{Name: "pedloy", Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,153 @@
+// pkg/auto/result.go - Structured results for deploy and destroy runs
+package auto
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrStacksFailed is returned when one or more stacks failed or were skipped.
+	ErrStacksFailed = errors.New("one or more stacks failed")
+	// ErrCancelled is returned when a run was cancelled before it completed.
+	ErrCancelled = errors.New("run cancelled")
+	// ErrStackTimeout marks the error of a stack that did not finish within its timeout.
+	ErrStackTimeout = errors.New("stack timed out")
+)
+
+// Status is the outcome of a single project:stack vertex.
+type Status string
+
+const (
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusSkipped   Status = "skipped"
+	// StatusInterrupted marks a vertex that was running when the run was cancelled.
+	StatusInterrupted Status = "interrupted"
+	// StatusTimedOut marks a vertex that did not finish within its timeout.
+	StatusTimedOut Status = "timed_out"
+	// StatusCancelled marks a vertex that was never started because the run was cancelled.
+	StatusCancelled Status = "cancelled"
+)
+
+// VertexResult is the outcome of running one project:stack vertex.
+type VertexResult struct {
+	Vertex   string
+	Project  string
+	Stack    string
+	Stage    int
+	Status   Status
+	Err      error
+	Duration time.Duration
+	// Upstream is the failed vertex that caused this vertex to be skipped.
+	Upstream string
+	// Changes holds the resource change counts reported by Pulumi, keyed by operation type.
+	Changes map[string]int
+}
+
+// Result is the outcome of a whole run, with one entry per vertex in execution order.
+type Result struct {
+	Operation string
+	Vertices  []VertexResult
+	Duration  time.Duration
+}
+
+// WithStatus returns the vertex results that finished with the given status.
+func (r *Result) WithStatus(status Status) []VertexResult {
+	var matched []VertexResult
+	for _, v := range r.Vertices {
+		if v.Status == status {
+			matched = append(matched, v)
+		}
+	}
+	return matched
+}
+
+// Drifted returns the vertices that reported resource changes other than "same".
+func (r *Result) Drifted() []VertexResult {
+	var drifted []VertexResult
+	for _, v := range r.Vertices {
+		if hasChanges(v.Changes) {
+			drifted = append(drifted, v)
+		}
+	}
+	return drifted
+}
+
+// hasChanges reports whether a set of resource change counts includes anything other than "same".
+func hasChanges(changes map[string]int) bool {
+	for op, count := range changes {
+		if op != "same" && count > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns an aggregate error describing every failed, timed out and skipped vertex, or nil if all succeeded.
+func (r *Result) Err() error {
+	failed := r.WithStatus(StatusFailed)
+	timedOut := r.WithStatus(StatusTimedOut)
+	skipped := r.WithStatus(StatusSkipped)
+	if len(failed) == 0 && len(timedOut) == 0 && len(skipped) == 0 {
+		return nil
+	}
+
+	names := func(vertices []VertexResult) string {
+		var names []string
+		for _, v := range vertices {
+			names = append(names, v.Vertex)
+		}
+		return strings.Join(names, ", ")
+	}
+	if len(timedOut) > 0 {
+		return fmt.Errorf("%w: %d failed (%s), %d timed out (%s), %d skipped", ErrStacksFailed,
+			len(failed), names(failed), len(timedOut), names(timedOut), len(skipped))
+	}
+	return fmt.Errorf("%w: %d failed (%s), %d skipped", ErrStacksFailed, len(failed), names(failed), len(skipped))
+}
+
+// newResult converts the scheduler outcome into a Result ordered like vertices.
+func newResult(operation string, vertices []string, stages map[string]int, sr scheduleResult, started time.Time) *Result {
+	result := &Result{
+		Operation: operation,
+		Duration:  time.Since(started),
+	}
+	succeeded := make(map[string]bool, len(sr.Succeeded))
+	for _, vertex := range sr.Succeeded {
+		succeeded[vertex] = true
+	}
+	for _, vertex := range vertices {
+		parts := strings.SplitN(vertex, ":", 2)
+		vr := VertexResult{
+			Vertex:   vertex,
+			Project:  parts[0],
+			Stack:    parts[1],
+			Stage:    stages[vertex],
+			Status:   StatusCancelled,
+			Duration: sr.Durations[vertex],
+		}
+		if succeeded[vertex] {
+			vr.Status = StatusSucceeded
+		}
+		if err, ok := sr.Failed[vertex]; ok {
+			vr.Status = StatusFailed
+			if errors.Is(err, ErrStackTimeout) {
+				vr.Status = StatusTimedOut
+			}
+			vr.Err = err
+		}
+		if err, ok := sr.Interrupted[vertex]; ok {
+			vr.Status = StatusInterrupted
+			vr.Err = err
+		}
+		if upstream, ok := sr.Skipped[vertex]; ok {
+			vr.Status = StatusSkipped
+			vr.Upstream = upstream
+		}
+		result.Vertices = append(result.Vertices, vr)
+	}
+	return result
+}
@@ -0,0 +1,40 @@
+// pkg/auto/retry.go - Retry stack operations that fail with transient errors
+package auto
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// withRetries calls attempt until it succeeds, the stack's retry policy is exhausted or the
+// error is not retryable, waiting with exponential backoff between attempts. Attempts are
+// numbered from 1. No retry is made once the run has been cancelled.
+func withRetries(ctx context.Context, run stackRun, action string, attempt func(n int) error) error {
+	policy := run.retry
+	attempts := policy.Attempts()
+	for n := 1; ; n++ {
+		err := attempt(n)
+		if err == nil || n >= attempts || ctx.Err() != nil {
+			return err
+		}
+		if !policy.Retryable(err) {
+			run.logger.Info(action+" failed with an error that is not retryable", zap.Int("attempt", n))
+			return err
+		}
+
+		backoff := policy.Backoff(n)
+		run.logger.Warn(action+" failed, retrying",
+			zap.Int("attempt", n),
+			zap.Int("max_attempts", attempts),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
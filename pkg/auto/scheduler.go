@@ -0,0 +1,146 @@
+// pkg/auto/scheduler.go - Run graph vertices as soon as the vertices they wait on have finished
+package auto
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// scheduleResult records how each vertex finished.
+type scheduleResult struct {
+	// Failed holds the error from each vertex that failed.
+	Failed map[string]error
+	// Skipped maps each vertex that never ran to the failed vertex upstream of it.
+	Skipped map[string]string
+	// Interrupted holds the error from each vertex that was running when the run was cancelled.
+	Interrupted map[string]error
+	// Succeeded lists the vertices that ran without error, in completion order.
+	Succeeded []string
+	// Durations records how long each vertex that ran took.
+	Durations map[string]time.Duration
+}
+
+// schedule runs fn for every vertex, starting each one as soon as all of the vertices it
+// waits on have finished rather than waiting for a whole stage to complete. For deploys
+// waitsOn is the dependency map; for destroys it is the reverse (dependents) map.
+// When a vertex fails, everything waiting on it, directly or transitively, is skipped.
+// Ready vertices that would exceed a concurrency limit are held until a slot frees up.
+// Once ctx is cancelled no further vertices are started; those already running finish
+// and, if they fail, are recorded as interrupted rather than failed.
+func schedule(ctx context.Context, vertices []string, waitsOn map[string][]string, limits *limiter, fn func(vertex string) error) scheduleResult {
+	// Count outstanding prerequisites and record who is waiting on each vertex
+	pending := make(map[string]int, len(vertices))
+	waiters := make(map[string][]string)
+	position := make(map[string]int, len(vertices))
+	for i, vertex := range vertices {
+		position[vertex] = i
+		pending[vertex] = len(waitsOn[vertex])
+		for _, prereq := range waitsOn[vertex] {
+			waiters[prereq] = append(waiters[prereq], vertex)
+		}
+	}
+
+	type completion struct {
+		vertex   string
+		err      error
+		duration time.Duration
+	}
+	done := make(chan completion)
+	var wg sync.WaitGroup
+
+	start := func(vertex string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started := time.Now()
+			err := fn(vertex)
+			done <- completion{vertex: vertex, err: err, duration: time.Since(started)}
+		}()
+	}
+
+	// ready holds vertices whose prerequisites have finished, in topological order
+	var ready []string
+	running := 0
+	startReady := func() {
+		if ctx.Err() != nil {
+			return
+		}
+		held := ready[:0]
+		for _, vertex := range ready {
+			if !limits.allows(vertex) {
+				held = append(held, vertex)
+				continue
+			}
+			limits.acquire(vertex)
+			start(vertex)
+			running++
+		}
+		ready = held
+	}
+	markReady := func(vertex string) {
+		i := sort.Search(len(ready), func(i int) bool { return position[ready[i]] > position[vertex] })
+		ready = append(ready, "")
+		copy(ready[i+1:], ready[i:])
+		ready[i] = vertex
+	}
+
+	for _, vertex := range vertices {
+		if pending[vertex] == 0 {
+			ready = append(ready, vertex)
+		}
+	}
+	startReady()
+
+	result := scheduleResult{
+		Failed:      make(map[string]error),
+		Skipped:     make(map[string]string),
+		Interrupted: make(map[string]error),
+		Durations:   make(map[string]time.Duration),
+	}
+
+	// skip marks every vertex downstream of a failure so that it is never started
+	var skip func(vertex string, failed string)
+	skip = func(vertex string, failed string) {
+		for _, waiter := range waiters[vertex] {
+			if _, ok := result.Skipped[waiter]; ok {
+				continue
+			}
+			result.Skipped[waiter] = failed
+			skip(waiter, failed)
+		}
+	}
+
+	for running > 0 {
+		c := <-done
+		running--
+		limits.release(c.vertex)
+		result.Durations[c.vertex] = c.duration
+		if c.err != nil && ctx.Err() != nil {
+			result.Interrupted[c.vertex] = c.err
+			continue
+		}
+		if c.err != nil {
+			result.Failed[c.vertex] = c.err
+			skip(c.vertex, c.vertex)
+			startReady()
+			continue
+		}
+		result.Succeeded = append(result.Succeeded, c.vertex)
+
+		for _, waiter := range waiters[c.vertex] {
+			pending[waiter]--
+			if _, skipped := result.Skipped[waiter]; skipped {
+				continue
+			}
+			if pending[waiter] == 0 {
+				markReady(waiter)
+			}
+		}
+		startReady()
+	}
+	wg.Wait()
+
+	return result
+}
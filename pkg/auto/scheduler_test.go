@@ -0,0 +1,310 @@
+package auto
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	proj "github.com/jaxxstorm/pedloy/pkg/project"
+)
+
+// recorder records which vertices ran.
+type recorder struct {
+	mu      sync.Mutex
+	started []string
+}
+
+func (r *recorder) start(vertex string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started = append(r.started, vertex)
+}
+
+func (r *recorder) ran() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ran := append([]string(nil), r.started...)
+	sort.Strings(ran)
+	return ran
+}
+
+func TestScheduleSlowBranchDoesNotBlockIndependentOne(t *testing.T) {
+	// a is slow; b and c form an independent branch that must finish while a is still running
+	vertices := []string{"a", "b", "c"}
+	waitsOn := map[string][]string{"c": {"b"}}
+	cDone := make(chan struct{})
+
+	result := schedule(context.Background(), vertices, waitsOn, nil, func(vertex string) error {
+		switch vertex {
+		case "a":
+			select {
+			case <-cDone:
+				return nil
+			case <-time.After(5 * time.Second):
+				return errors.New("a blocked the independent branch")
+			}
+		case "c":
+			close(cDone)
+		}
+		return nil
+	})
+
+	if len(result.Failed) > 0 {
+		t.Fatalf("unexpected failures: %v", result.Failed)
+	}
+	if want := []string{"b", "c", "a"}; !reflect.DeepEqual(result.Succeeded, want) {
+		t.Errorf("completion order = %v, want %v", result.Succeeded, want)
+	}
+}
+
+func TestScheduleWaitsForPrerequisites(t *testing.T) {
+	// d waits on b and c, which both wait on a
+	vertices := []string{"a", "b", "c", "d"}
+	waitsOn := map[string][]string{"b": {"a"}, "c": {"a"}, "d": {"b", "c"}}
+
+	var mu sync.Mutex
+	finished := make(map[string]bool)
+	result := schedule(context.Background(), vertices, waitsOn, nil, func(vertex string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, prereq := range waitsOn[vertex] {
+			if !finished[prereq] {
+				t.Errorf("%s started before %s finished", vertex, prereq)
+			}
+		}
+		finished[vertex] = true
+		return nil
+	})
+
+	if len(result.Succeeded) != len(vertices) {
+		t.Fatalf("succeeded = %v, want all of %v", result.Succeeded, vertices)
+	}
+	if result.Succeeded[0] != "a" || result.Succeeded[3] != "d" {
+		t.Errorf("completion order = %v, want a first and d last", result.Succeeded)
+	}
+	for _, vertex := range vertices {
+		if _, ok := result.Durations[vertex]; !ok {
+			t.Errorf("no duration recorded for %s", vertex)
+		}
+	}
+}
+
+func TestScheduleSkipsDescendantsOfFailure(t *testing.T) {
+	// a fails: b and c below it and d, which also waits on the healthy e, are skipped
+	vertices := []string{"a", "e", "b", "c", "d", "f"}
+	waitsOn := map[string][]string{
+		"b": {"a"},
+		"c": {"b"},
+		"d": {"a", "e"},
+		"f": {"e"},
+	}
+	rec := &recorder{}
+	failure := errors.New("boom")
+
+	result := schedule(context.Background(), vertices, waitsOn, nil, func(vertex string) error {
+		rec.start(vertex)
+		if vertex == "a" {
+			return failure
+		}
+		return nil
+	})
+
+	if !errors.Is(result.Failed["a"], failure) || len(result.Failed) != 1 {
+		t.Errorf("failed = %v, want only a", result.Failed)
+	}
+	if want := map[string]string{"b": "a", "c": "a", "d": "a"}; !reflect.DeepEqual(result.Skipped, want) {
+		t.Errorf("skipped = %v, want %v", result.Skipped, want)
+	}
+	if want := []string{"a", "e", "f"}; !reflect.DeepEqual(rec.ran(), want) {
+		t.Errorf("ran = %v, want %v", rec.ran(), want)
+	}
+	sort.Strings(result.Succeeded)
+	if want := []string{"e", "f"}; !reflect.DeepEqual(result.Succeeded, want) {
+		t.Errorf("succeeded = %v, want %v", result.Succeeded, want)
+	}
+}
+
+func TestScheduleReverse(t *testing.T) {
+	// b depends on a and c depends on b. Walking the reverse edges, as destroy does, runs
+	// c first, and a failure in b keeps a from running.
+	dependents := map[string][]string{"a": {"b"}, "b": {"c"}}
+	order := []string{"c", "b", "a"}
+
+	var mu sync.Mutex
+	var ran []string
+	result := schedule(context.Background(), order, dependents, nil, func(vertex string) error {
+		mu.Lock()
+		ran = append(ran, vertex)
+		mu.Unlock()
+		return nil
+	})
+	if want := []string{"c", "b", "a"}; !reflect.DeepEqual(ran, want) || !reflect.DeepEqual(result.Succeeded, want) {
+		t.Errorf("ran %v and succeeded %v, want %v", ran, result.Succeeded, want)
+	}
+
+	result = schedule(context.Background(), order, dependents, nil, func(vertex string) error {
+		if vertex == "b" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	if want := map[string]string{"a": "b"}; !reflect.DeepEqual(result.Skipped, want) {
+		t.Errorf("skipped = %v, want %v", result.Skipped, want)
+	}
+	if want := []string{"c"}; !reflect.DeepEqual(result.Succeeded, want) {
+		t.Errorf("succeeded = %v, want %v", result.Succeeded, want)
+	}
+}
+
+func TestScheduleLimits(t *testing.T) {
+	projects := []proj.Project{
+		{Name: "net", Parallel: 1, Stacks: proj.Stacks{{Name: "a"}, {Name: "b"}, {Name: "c"}}},
+		{Name: "app", Labels: []string{"db"}, Stacks: proj.Stacks{{Name: "a"}, {Name: "b"}}},
+		{Name: "data", Labels: []string{"db"}, Stacks: proj.Stacks{{Name: "a"}, {Name: "b"}}},
+		{Name: "web", Stacks: proj.Stacks{{Name: "a"}, {Name: "b"}, {Name: "c"}}},
+	}
+	var vertices []string
+	for _, p := range projects {
+		for _, s := range p.Stacks {
+			vertices = append(vertices, p.Name+":"+s.Name)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		concurrency proj.Concurrency
+		// max is the most vertices of each group that may run at once
+		max map[string]int
+	}{
+		{
+			name:        "global limit",
+			concurrency: proj.Concurrency{Parallel: 2},
+			max:         map[string]int{"": 2, "net": 1},
+		},
+		{
+			name:        "project and label limits",
+			concurrency: proj.Concurrency{Labels: map[string]int{"db": 1}},
+			max:         map[string]int{"net": 1, "db": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := newLimiter(tt.concurrency, projects, vertices)
+
+			group := func(vertex string) []string {
+				p, _ := findProject(projects, vertex)
+				groups := []string{""}
+				if p.Name == "net" {
+					groups = append(groups, "net")
+				}
+				if len(p.Labels) > 0 {
+					groups = append(groups, "db")
+				}
+				return groups
+			}
+			var mu sync.Mutex
+			running := make(map[string]int)
+			peak := make(map[string]int)
+
+			result := schedule(context.Background(), vertices, nil, limits, func(vertex string) error {
+				mu.Lock()
+				for _, g := range group(vertex) {
+					running[g]++
+					if running[g] > peak[g] {
+						peak[g] = running[g]
+					}
+				}
+				mu.Unlock()
+
+				// Give held vertices a chance to start if a limit were not enforced
+				time.Sleep(10 * time.Millisecond)
+
+				mu.Lock()
+				for _, g := range group(vertex) {
+					running[g]--
+				}
+				mu.Unlock()
+				return nil
+			})
+
+			// Every held vertex started once a slot was released
+			if len(result.Succeeded) != len(vertices) {
+				t.Fatalf("succeeded %v, want all %d vertices", result.Succeeded, len(vertices))
+			}
+			for g, max := range tt.max {
+				if peak[g] != max {
+					t.Errorf("group %q peaked at %d running, want %d", g, peak[g], max)
+				}
+			}
+			if limits.total != 0 {
+				t.Errorf("limiter still counts %d running vertices", limits.total)
+			}
+			for g, n := range limits.running {
+				if n != 0 {
+					t.Errorf("limiter still counts %d running in %s", n, g)
+				}
+			}
+		})
+	}
+}
+
+func TestScheduleCancellation(t *testing.T) {
+	t.Run("no new starts", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// b and c wait on a, and d is held by the limit; none may start once a cancels the run
+		vertices := []string{"p:a", "p:d", "p:b", "p:c"}
+		waitsOn := map[string][]string{"p:b": {"p:a"}, "p:c": {"p:a"}}
+		limits := newLimiter(proj.Concurrency{Parallel: 1}, nil, vertices)
+		rec := &recorder{}
+
+		result := schedule(ctx, vertices, waitsOn, limits, func(vertex string) error {
+			rec.start(vertex)
+			if vertex == "p:a" {
+				cancel()
+			}
+			return nil
+		})
+
+		if want := []string{"p:a"}; !reflect.DeepEqual(rec.ran(), want) {
+			t.Errorf("ran = %v, want %v", rec.ran(), want)
+		}
+		if want := []string{"p:a"}; !reflect.DeepEqual(result.Succeeded, want) {
+			t.Errorf("succeeded = %v, want %v", result.Succeeded, want)
+		}
+		if len(result.Failed)+len(result.Skipped)+len(result.Interrupted) > 0 {
+			t.Errorf("unexpected failed %v, skipped %v or interrupted %v", result.Failed, result.Skipped, result.Interrupted)
+		}
+	})
+
+	t.Run("running vertices are interrupted", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		vertices := []string{"a", "b", "c"}
+		waitsOn := map[string][]string{"c": {"a"}}
+		result := schedule(ctx, vertices, waitsOn, nil, func(vertex string) error {
+			switch vertex {
+			case "a":
+				cancel()
+				return context.Canceled
+			case "b":
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return nil
+		})
+
+		if len(result.Interrupted) != 2 || result.Interrupted["a"] == nil || result.Interrupted["b"] == nil {
+			t.Errorf("interrupted = %v, want a and b", result.Interrupted)
+		}
+		if len(result.Failed) > 0 || len(result.Skipped) > 0 || len(result.Succeeded) > 0 {
+			t.Errorf("unexpected failed %v, skipped %v or succeeded %v", result.Failed, result.Skipped, result.Succeeded)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+// pkg/auto/state.go - Persist the outcome of each stack so a failed run can be resumed
+package auto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jaxxstorm/pedloy/pkg/graph"
+	proj "github.com/jaxxstorm/pedloy/pkg/project"
+)
+
+// runStateVersion is bumped whenever the layout of the run-state file changes.
+const runStateVersion = 1
+
+// VertexState is the recorded outcome of one project:stack vertex.
+type VertexState struct {
+	Status Status `json:"status"`
+	// ConfigHash identifies the project definition and source the vertex was run with.
+	ConfigHash string    `json:"configHash"`
+	StartedAt  time.Time `json:"startedAt,omitempty"`
+	FinishedAt time.Time `json:"finishedAt,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// RunState is the run-state file. It is rewritten after every stack finishes, so it
+// reflects progress even if pedloy itself is killed.
+type RunState struct {
+	Version   int    `json:"version"`
+	Operation string `json:"operation"`
+	// GraphHash identifies the vertices and edges of the graph that was run.
+	GraphHash string                  `json:"graphHash"`
+	UpdatedAt time.Time               `json:"updatedAt"`
+	Vertices  map[string]*VertexState `json:"vertices"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// newRunState starts the run state for an operation over the graph. When resume is set,
+// the previous state is loaded from path and must have been written by the same operation
+// over the same graph. A missing state file just means there is nothing to resume.
+func newRunState(path string, operation string, g *graph.Graph, resume bool) (*RunState, error) {
+	state := &RunState{
+		Version:   runStateVersion,
+		Operation: operation,
+		GraphHash: graphHash(g),
+		Vertices:  make(map[string]*VertexState),
+		path:      path,
+	}
+	if !resume {
+		return state, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run state: %w", err)
+	}
+	var previous RunState
+	if err := json.Unmarshal(data, &previous); err != nil {
+		return nil, fmt.Errorf("failed to parse run state %s: %w", path, err)
+	}
+
+	switch {
+	case previous.Version != runStateVersion:
+		return nil, fmt.Errorf("run state %s was written by an incompatible version of pedloy", path)
+	case previous.Operation != operation:
+		return nil, fmt.Errorf("run state %s is for a %s, not a %s", path, previous.Operation, operation)
+	case previous.GraphHash != state.GraphHash:
+		return nil, fmt.Errorf("the dependency graph has changed since run state %s was written; run again without --resume", path)
+	}
+	if previous.Vertices != nil {
+		state.Vertices = previous.Vertices
+	}
+	return state, nil
+}
+
+// completed reports whether the vertex already succeeded with the same configuration.
+func (s *RunState) completed(vertex string, configHash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.Vertices[vertex]
+	return ok && v.Status == StatusSucceeded && v.ConfigHash == configHash
+}
+
+// record stores the outcome of a vertex and saves the state.
+func (s *RunState) record(vertex string, v VertexState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Vertices[vertex] = &v
+	return s.save()
+}
+
+// finish records the final status of every vertex in the result, keeping the timestamps
+// of vertices that were not run, and saves the state.
+func (s *RunState) finish(result *Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, vr := range result.Vertices {
+		v, ok := s.Vertices[vr.Vertex]
+		if !ok {
+			v = &VertexState{}
+			s.Vertices[vr.Vertex] = v
+		}
+		// A succeeded vertex that did not run this time, because it was skipped by the
+		// resume or the run was cancelled before reaching it, keeps its recorded outcome
+		if v.Status == StatusSucceeded && (vr.Status == StatusSucceeded || vr.Status == StatusCancelled) {
+			continue
+		}
+		v.Status = vr.Status
+		v.Error = ""
+		if vr.Err != nil {
+			v.Error = vr.Err.Error()
+		}
+	}
+	return s.save()
+}
+
+// save writes the state atomically, so an interrupted write never leaves a truncated file.
+func (s *RunState) save() error {
+	s.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run state: %w", err)
+	}
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create run state directory: %w", err)
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write run state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write run state: %w", err)
+	}
+	return nil
+}
+
+// graphHash hashes the vertices and edges of a graph.
+func graphHash(g *graph.Graph) string {
+	return hashJSON(struct {
+		Vertices []string     `json:"vertices"`
+		Edges    []graph.Edge `json:"edges"`
+	}{g.Vertices, g.Edges()})
+}
+
+// vertexConfigHash hashes everything that affects how a vertex is run: its project
+// definition, the stack name and where the project source comes from.
+func vertexConfigHash(project proj.Project, stack string, source proj.ProjectSource) string {
+	return hashJSON(struct {
+		Project   proj.Project `json:"project"`
+		Stack     string       `json:"stack"`
+		GitURL    string       `json:"gitUrl"`
+		Revision  string       `json:"revision"`
+		LocalPath string       `json:"localPath"`
+	}{project, stack, source.GitURL, source.Revision(), source.LocalPath})
+}
+
+func hashJSON(v interface{}) string {
+	// Maps are encoded with sorted keys, so equal values always hash the same
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
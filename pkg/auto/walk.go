@@ -0,0 +1,354 @@
+// pkg/auto/walk.go - Walk the dependency graph and run an operation against every stack
+package auto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaxxstorm/pedloy/pkg/config"
+	"github.com/jaxxstorm/pedloy/pkg/graph"
+	proj "github.com/jaxxstorm/pedloy/pkg/project"
+	"go.uber.org/zap"
+)
+
+// operation describes a single pass over the dependency graph.
+type operation struct {
+	// name identifies the operation in logs and results, e.g. "deploy".
+	name string
+	// noun is used in log messages, e.g. "Deployment".
+	noun string
+	// verb is used in error messages when it differs from name, e.g. "check drift of".
+	verb string
+	// reverse runs dependents before the stacks they depend on, as destroy does.
+	reverse bool
+}
+
+// stackRun is the project:stack vertex an operation is run against.
+type stackRun struct {
+	vertex  string
+	project proj.Project
+	stack   string
+	stage   int
+	source  proj.ProjectSource
+	logger  *zap.Logger
+	// env holds the stack's environment variables, resolved before the run starts.
+	env proj.EnvMap
+	// config holds the stack's Pulumi config, resolved before the run starts.
+	config proj.ConfigMap
+	// envs holds the resolved env of every stack in the run and of the upstream stacks
+	// their configFrom entries read, keyed by vertex.
+	envs map[string]proj.EnvMap
+	// logEnvValues holds the env var names whose values may be logged.
+	logEnvValues map[string]bool
+	// redact removes secret env values from output, logs and errors.
+	redact *redactor
+	// retry is the retry policy for the stack, with its own settings applied over the global ones.
+	retry proj.RetryPolicy
+}
+
+// stackFunc runs an operation against one stack and returns the resource change counts it reported.
+type stackFunc func(ctx context.Context, run stackRun) (map[string]int, error)
+
+// walk builds the dependency graph, applies the selection, prepares the project source and
+// runs fn against every stack in dependency order (or reverse order), skipping stacks
+// downstream of a failure.
+func walk(ctx context.Context, logger *zap.Logger, op operation, projects []proj.Project, opts Options, fn stackFunc) (*Result, error) {
+	logger.Info(fmt.Sprintf("Starting %s", strings.ToLower(op.noun)))
+
+	started := time.Now()
+
+	// Build the dependency graph
+	g, err := graph.Build(projects)
+	if err != nil {
+		logger.Error("Failed to determine execution groups", zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", config.ErrInvalidConfig, err)
+	}
+	g, err = g.Select(opts.Selection)
+	if err != nil {
+		logger.Error("Failed to select stacks", zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", config.ErrInvalidConfig, err)
+	}
+
+	// Resolve every stack's env up front so a missing variable or env file stops the run before it starts.
+	// Upstream stacks read by configFrom need their env too, even when they are not selected.
+	envs := make(map[string]proj.EnvMap, len(g.Vertices))
+	for _, vertex := range envVertices(projects, g.Vertices) {
+		projectDef, stackName := findProject(projects, vertex)
+		env, err := projectDef.StackEnv(stackName)
+		if err != nil {
+			err = fmt.Errorf("failed to resolve env for %s: %w", vertex, err)
+			logger.Error("Invalid stack env", zap.Error(err))
+			return nil, fmt.Errorf("%w: %w", config.ErrInvalidConfig, err)
+		}
+		envs[vertex] = env
+	}
+	configs := make(map[string]proj.ConfigMap, len(g.Vertices))
+	for _, vertex := range g.Vertices {
+		projectDef, stackName := findProject(projects, vertex)
+		cfg, err := projectDef.StackConfigValues(stackName)
+		if err != nil {
+			err = fmt.Errorf("failed to resolve config for %s: %w", vertex, err)
+			logger.Error("Invalid stack config", zap.Error(err))
+			return nil, fmt.Errorf("%w: %w", config.ErrInvalidConfig, err)
+		}
+		configs[vertex] = cfg
+	}
+	if err := checkAWSProfiles(g.Vertices, envs); err != nil {
+		logger.Error("Invalid AWS profile", zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", config.ErrInvalidConfig, err)
+	}
+	redact := newRedactor(envs)
+	logger = redact.Logger(logger)
+	logEnvValues := make(map[string]bool, len(opts.LogEnvValues))
+	for _, k := range opts.LogEnvValues {
+		logEnvValues[k] = true
+	}
+
+	var state *RunState
+	if opts.StateFile != "" {
+		state, err = newRunState(opts.StateFile, op.name, g, opts.Resume)
+		if err != nil {
+			logger.Error("Failed to load run state", zap.Error(err))
+			return nil, err
+		}
+	}
+	executionGroups := g.Stages()
+	stages := stageIndex(executionGroups)
+	order := g.Vertices
+	waitsOn := g.Dependencies
+
+	if op.reverse {
+		// Reverse stages are numbered from the end of the graph
+		for i, j := 0, len(executionGroups)-1; i < j; i, j = i+1, j-1 {
+			executionGroups[i], executionGroups[j] = executionGroups[j], executionGroups[i]
+		}
+		stages = stageIndex(executionGroups)
+
+		order = make([]string, 0, len(g.Vertices))
+		for i := len(g.Vertices) - 1; i >= 0; i-- {
+			order = append(order, g.Vertices[i])
+		}
+		waitsOn = g.Dependents()
+	}
+
+	// Log the execution schedule
+	logger.Info(op.noun + " Schedule")
+	for i, group := range executionGroups {
+		logger.Info(op.noun+" Stage",
+			zap.Int("stage", i+1),
+			zap.Strings("stacks", group))
+	}
+
+	source, cleanup, err := prepareSource(ctx, opts.Source, logger)
+	if err != nil {
+		logger.Error("Failed to prepare project source", zap.Error(err))
+		return nil, fmt.Errorf("failed to prepare project source: %w", err)
+	}
+	defer cleanup()
+
+	changes := make(map[string]map[string]int)
+	mu := &sync.Mutex{}
+
+	// Run each stack as soon as the stacks it waits on have finished
+	limits := newLimiter(opts.Concurrency, projects, order)
+	sr := schedule(ctx, order, waitsOn, limits, func(vertex string) error {
+		projectDef, stackName := findProject(projects, vertex)
+		stackDef, _ := projectDef.Stack(stackName)
+		run := stackRun{
+			vertex:       vertex,
+			project:      projectDef,
+			stack:        stackName,
+			stage:        stages[vertex],
+			source:       source,
+			env:          envs[vertex],
+			envs:         envs,
+			config:       configs[vertex],
+			logEnvValues: logEnvValues,
+			redact:       redact,
+			retry:        opts.Retry.Merge(stackDef.RetryPolicy),
+			logger: logger.With(
+				zap.Int("stage", stages[vertex]),
+				zap.String("project", projectDef.Name),
+				zap.String("stack", stackName),
+			),
+		}
+
+		configHash := vertexConfigHash(projectDef, stackName, opts.Source)
+		if state != nil && state.completed(vertex, configHash) {
+			run.logger.Info("Skipping stack, it succeeded in the run being resumed")
+			return nil
+		}
+
+		stackCtx := ctx
+		timeout := opts.StackTimeout
+		if t := projectDef.StackTimeout(stackName); t > 0 {
+			timeout = t
+		}
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			stackCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		startedAt := time.Now().UTC()
+		stackChanges, err := fn(stackCtx, run)
+		err = redact.Error(err)
+		if err != nil && ctx.Err() == nil && errors.Is(stackCtx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("%w after %s: %w", ErrStackTimeout, timeout, err)
+		}
+		if stackChanges != nil {
+			mu.Lock()
+			changes[vertex] = stackChanges
+			mu.Unlock()
+		}
+		if state != nil {
+			vs := VertexState{
+				Status:     StatusSucceeded,
+				ConfigHash: configHash,
+				StartedAt:  startedAt,
+				FinishedAt: time.Now().UTC(),
+			}
+			if err != nil {
+				vs.Status = StatusFailed
+				if errors.Is(err, ErrStackTimeout) {
+					vs.Status = StatusTimedOut
+				}
+				vs.Error = err.Error()
+			}
+			if serr := state.record(vertex, vs); serr != nil {
+				run.logger.Warn("Failed to save run state", zap.Error(serr))
+			}
+		}
+		if err != nil {
+			verb := op.name
+			if op.verb != "" {
+				verb = op.verb
+			}
+			err = fmt.Errorf("failed to %s %s: %w", verb, vertex, err)
+			run.logger.Error(op.noun+" failed", zap.Error(err))
+			return err
+		}
+		return nil
+	})
+
+	result := newResult(op.name, order, stages, sr, started)
+	for i := range result.Vertices {
+		result.Vertices[i].Changes = changes[result.Vertices[i].Vertex]
+	}
+
+	logSummary(logger, result)
+	if state != nil {
+		if err := state.finish(result); err != nil {
+			logger.Warn("Failed to save run state", zap.Error(err))
+		} else {
+			logger.Info("Saved run state", zap.String("path", opts.StateFile))
+		}
+	}
+	if ctx.Err() != nil {
+		logger.Error(op.noun+" cancelled", zap.Error(ctx.Err()))
+		return result, fmt.Errorf("%w: %w", ErrCancelled, ctx.Err())
+	}
+	if err := result.Err(); err != nil {
+		logger.Error(op.noun + " completed with errors")
+		for _, v := range result.WithStatus(StatusFailed) {
+			logger.Error("Resource issue", zap.Error(v.Err))
+		}
+		return result, err
+	}
+
+	logger.Info(op.noun + " completed successfully")
+	return result, nil
+}
+
+// stageIndex maps each vertex to the 1-based stage it appears in, for display only.
+func stageIndex(stages [][]string) map[string]int {
+	index := make(map[string]int)
+	for i, group := range stages {
+		for _, vertex := range group {
+			index[vertex] = i + 1
+		}
+	}
+	return index
+}
+
+// envVertices returns the vertices followed by the upstream stacks their configFrom entries read.
+func envVertices(projects []proj.Project, vertices []string) []string {
+	seen := make(map[string]bool, len(vertices))
+	all := make([]string, 0, len(vertices))
+	for _, vertex := range vertices {
+		seen[vertex] = true
+		all = append(all, vertex)
+	}
+	for _, vertex := range vertices {
+		projectDef, stackName := findProject(projects, vertex)
+		sc, _ := projectDef.Stack(stackName)
+		for _, ref := range sc.ConfigFrom {
+			depProject, depStack := ref.Source(stackName)
+			upstream := depProject + ":" + depStack
+			if !seen[upstream] {
+				seen[upstream] = true
+				all = append(all, upstream)
+			}
+		}
+	}
+	return all
+}
+
+// findProject parses a project:stack vertex ID and returns the matching project definition.
+func findProject(projects []proj.Project, vertex string) (proj.Project, string) {
+	parts := strings.Split(vertex, ":")
+	projectName, stackName := parts[0], parts[1]
+
+	var projectDef proj.Project
+	for _, p := range projects {
+		if p.Name == projectName {
+			projectDef = p
+			break
+		}
+	}
+	return projectDef, stackName
+}
+
+// logSummary logs the failed, skipped and succeeded stacks of a run separately.
+func logSummary(logger *zap.Logger, result *Result) {
+	names := func(status Status) []string {
+		var vertices []string
+		for _, v := range result.WithStatus(status) {
+			vertices = append(vertices, v.Vertex)
+		}
+		return vertices
+	}
+
+	fields := []zap.Field{
+		zap.Strings("succeeded", names(StatusSucceeded)),
+		zap.Strings("failed", names(StatusFailed)),
+		zap.Strings("skipped", names(StatusSkipped)),
+	}
+	if timedOut := names(StatusTimedOut); len(timedOut) > 0 {
+		fields = append(fields, zap.Strings("timed_out", timedOut))
+	}
+	if interrupted, cancelled := names(StatusInterrupted), names(StatusCancelled); len(interrupted)+len(cancelled) > 0 {
+		fields = append(fields,
+			zap.Strings("interrupted", interrupted),
+			zap.Strings("not_started", cancelled),
+		)
+	}
+	fields = append(fields, zap.Duration("duration", result.Duration))
+	logger.Info("Run Summary", fields...)
+	for _, v := range result.WithStatus(StatusSkipped) {
+		logger.Warn("Stack skipped (upstream failed)",
+			zap.String("vertex", v.Vertex),
+			zap.String("failed_upstream", v.Upstream),
+		)
+	}
+	for _, v := range result.WithStatus(StatusInterrupted) {
+		logger.Warn("Stack interrupted",
+			zap.String("vertex", v.Vertex),
+			zap.Error(v.Err),
+		)
+	}
+}
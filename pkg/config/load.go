@@ -2,27 +2,57 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"github.com/jaxxstorm/pedloy/pkg/project"
 	"gopkg.in/yaml.v3"
 	"os"
+	"path/filepath"
 
 	"github.com/spf13/viper"
 )
 
-func LoadConfig(v *viper.Viper) ([]project.Project, error) {
+// ErrInvalidConfig marks errors caused by a missing or invalid configuration file.
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// LoadConfig reads and parses the configuration file named by the config setting.
+func LoadConfig(v *viper.Viper) (*project.Config, error) {
 	configPath := v.GetString("config") // Use viper to get the config path
 	file, err := os.Open(configPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open config file: %w", err)
+		return nil, fmt.Errorf("%w: failed to open config file: %w", ErrInvalidConfig, err)
 	}
 	defer file.Close()
 
 	var cfg project.Config
 	decoder := yaml.NewDecoder(file)
 	if err := decoder.Decode(&cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+		return nil, fmt.Errorf("%w: failed to parse config file: %w", ErrInvalidConfig, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+
+	// Every project carries the global defaults so they are merged below its own settings
+	for i := range cfg.Projects {
+		cfg.Projects[i].Defaults = cfg.Defaults
+	}
+
+	// Env files are relative to the configuration file
+	dir := filepath.Dir(configPath)
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+	for i := range cfg.Projects {
+		p := &cfg.Projects[i]
+		p.EnvFile.Path = resolve(p.EnvFile.Path)
+		for j := range p.Stacks {
+			p.Stacks[j].EnvFile.Path = resolve(p.Stacks[j].EnvFile.Path)
+		}
 	}
 
-	return cfg.Projects, nil
+	return &cfg, nil
 }
@@ -0,0 +1,95 @@
+// pkg/graph/cycles.go - Find and report dependency cycles
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dominikbraun/graph"
+	p "github.com/jaxxstorm/pedloy/pkg/project"
+)
+
+// maxCycles bounds how many cycles are reported, since densely connected graphs can contain a great many.
+const maxCycles = 50
+
+// CycleError reports every dependency cycle found in the configuration.
+type CycleError struct {
+	// Cycles holds each cycle as a path of vertices where each depends on the next,
+	// ending with the vertex it started from.
+	Cycles [][]string
+}
+
+func (e *CycleError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "found %d dependency cycle(s):", len(e.Cycles))
+	for _, cycle := range e.Cycles {
+		fmt.Fprintf(&b, "\n  %s", strings.Join(cycle, " -> "))
+	}
+	if len(e.Cycles) >= maxCycles {
+		fmt.Fprintf(&b, "\n  (only the first %d cycles are shown)", maxCycles)
+	}
+	return b.String()
+}
+
+// Cycles returns every dependency cycle in the configured projects, or nil if there are none.
+func Cycles(projects []p.Project) ([][]string, error) {
+	g, dependencies, err := buildDependencies(projects)
+	if err != nil {
+		return nil, err
+	}
+	return findCycles(g, dependencies), nil
+}
+
+// findCycles enumerates the elementary cycles of the dependency map. Each cycle is found
+// once, starting from its lexically smallest vertex, and only passes through vertices
+// that sort after that start. The search is confined to strongly connected components,
+// so an acyclic graph is never walked.
+func findCycles(g graph.Graph[string, string], dependencies map[string][]string) [][]string {
+	components, err := graph.StronglyConnectedComponents(g)
+	if err != nil {
+		return nil
+	}
+	component := make(map[string]int)
+	for i, vertices := range components {
+		for _, vertex := range vertices {
+			component[vertex] = i
+		}
+	}
+
+	vertices := make([]string, 0, len(dependencies))
+	for vertex := range dependencies {
+		vertices = append(vertices, vertex)
+	}
+	sort.Strings(vertices)
+
+	var cycles [][]string
+	for _, start := range vertices {
+		onPath := map[string]bool{start: true}
+		path := []string{start}
+
+		var visit func(vertex string)
+		visit = func(vertex string) {
+			deps := append([]string(nil), dependencies[vertex]...)
+			sort.Strings(deps)
+			for _, dep := range deps {
+				if len(cycles) >= maxCycles {
+					return
+				}
+				switch {
+				case dep == start:
+					cycle := append(append([]string(nil), path...), start)
+					cycles = append(cycles, cycle)
+				case dep > start && !onPath[dep] && component[dep] == component[start]:
+					onPath[dep] = true
+					path = append(path, dep)
+					visit(dep)
+					path = path[:len(path)-1]
+					onPath[dep] = false
+				}
+			}
+		}
+		visit(start)
+	}
+	return cycles
+}
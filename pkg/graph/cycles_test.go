@@ -0,0 +1,120 @@
+package graph
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	p "github.com/jaxxstorm/pedloy/pkg/project"
+)
+
+// project returns a project with a dev stack depending on the dev stacks of the given projects.
+func project(name string, dependsOn ...string) p.Project {
+	proj := p.Project{Name: name, Stacks: p.Stacks{{Name: "dev"}}}
+	for _, dep := range dependsOn {
+		proj.DependsOn = append(proj.DependsOn, p.Dependency{Project: dep})
+	}
+	return proj
+}
+
+func TestCycles(t *testing.T) {
+	tests := []struct {
+		name     string
+		projects []p.Project
+		want     [][]string
+	}{
+		{
+			name:     "acyclic",
+			projects: []p.Project{project("a"), project("b", "a"), project("c", "a", "b")},
+		},
+		{
+			name:     "single cycle",
+			projects: []p.Project{project("a", "b"), project("b", "c"), project("c", "a"), project("d", "a")},
+			want:     [][]string{{"a:dev", "b:dev", "c:dev", "a:dev"}},
+		},
+		{
+			name:     "self-loop",
+			projects: []p.Project{project("a", "a"), project("b", "a")},
+			want:     [][]string{{"a:dev", "a:dev"}},
+		},
+		{
+			name: "multiple cycles",
+			projects: []p.Project{
+				project("a", "b"), project("b", "a"),
+				project("c", "d"), project("d", "e"), project("e", "c"),
+				project("f", "a", "c"),
+			},
+			want: [][]string{
+				{"a:dev", "b:dev", "a:dev"},
+				{"c:dev", "d:dev", "e:dev", "c:dev"},
+			},
+		},
+		{
+			name:     "cycles sharing a vertex",
+			projects: []p.Project{project("a", "b", "c"), project("b", "a"), project("c", "a", "b")},
+			want: [][]string{
+				{"a:dev", "b:dev", "a:dev"},
+				{"a:dev", "c:dev", "a:dev"},
+				{"a:dev", "c:dev", "b:dev", "a:dev"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cycles, err := Cycles(tt.projects)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cycles, tt.want) {
+				t.Errorf("Cycles() = %v, want %v", cycles, tt.want)
+			}
+
+			_, err = Build(tt.projects)
+			var cycleErr *CycleError
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("Build() failed: %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &cycleErr) {
+				t.Fatalf("Build() error = %v, want a CycleError", err)
+			}
+			if !reflect.DeepEqual(cycleErr.Cycles, tt.want) {
+				t.Errorf("CycleError.Cycles = %v, want %v", cycleErr.Cycles, tt.want)
+			}
+			for _, cycle := range tt.want {
+				if !strings.Contains(err.Error(), strings.Join(cycle, " -> ")) {
+					t.Errorf("error %q does not name cycle %v", err, cycle)
+				}
+			}
+		})
+	}
+}
+
+func TestCyclesLimit(t *testing.T) {
+	// Every project depends on every other, so there are far more cycles than are reported
+	names := []string{"a", "b", "c", "d", "e", "f"}
+	var projects []p.Project
+	for _, name := range names {
+		var deps []string
+		for _, other := range names {
+			if other != name {
+				deps = append(deps, other)
+			}
+		}
+		projects = append(projects, project(name, deps...))
+	}
+
+	cycles, err := Cycles(projects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cycles) != maxCycles {
+		t.Errorf("found %d cycles, want the limit of %d", len(cycles), maxCycles)
+	}
+	if msg := (&CycleError{Cycles: cycles}).Error(); !strings.Contains(msg, "only the first 50 cycles are shown") {
+		t.Errorf("error does not mention the limit: %s", msg)
+	}
+}
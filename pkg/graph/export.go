@@ -0,0 +1,133 @@
+// pkg/graph/export.go - Export the dependency graph as DOT, Mermaid or JSON
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Formats lists the supported export formats.
+var Formats = []string{"dot", "mermaid", "json"}
+
+// Edge is a dependency edge, pointing from a vertex to a vertex that depends on it.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Edges returns every edge in execution order: from each dependency to its dependent.
+func (g *Graph) Edges() []Edge {
+	var edges []Edge
+	for _, vertex := range g.Vertices {
+		deps := append([]string(nil), g.Dependencies[vertex]...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			edges = append(edges, Edge{From: dep, To: vertex})
+		}
+	}
+	return edges
+}
+
+// Export writes the graph in the given format, with stages shown as ranks or subgraphs.
+func (g *Graph) Export(w io.Writer, format string) error {
+	switch format {
+	case "dot":
+		return g.writeDOT(w)
+	case "mermaid":
+		return g.writeMermaid(w)
+	case "json":
+		return g.writeJSON(w)
+	default:
+		return fmt.Errorf("unsupported graph format %q, expected one of: %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+func (g *Graph) writeDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph pedloy {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	for i, stage := range g.Stages() {
+		fmt.Fprintf(&b, "\n  subgraph cluster_stage_%d {\n", i+1)
+		fmt.Fprintf(&b, "    label=\"Stage %d\";\n", i+1)
+		b.WriteString("    rank=same;\n")
+		for _, vertex := range stage {
+			fmt.Fprintf(&b, "    %q;\n", vertex)
+		}
+		b.WriteString("  }\n")
+	}
+	if edges := g.Edges(); len(edges) > 0 {
+		b.WriteString("\n")
+		for _, edge := range edges {
+			fmt.Fprintf(&b, "  %q -> %q;\n", edge.From, edge.To)
+		}
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (g *Graph) writeMermaid(w io.Writer) error {
+	// Mermaid node IDs cannot contain colons, so number the vertices
+	ids := make(map[string]string, len(g.Vertices))
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, stage := range g.Stages() {
+		fmt.Fprintf(&b, "  subgraph stage_%d[\"Stage %d\"]\n", i+1, i+1)
+		for _, vertex := range stage {
+			id := fmt.Sprintf("n%d", len(ids))
+			ids[vertex] = id
+			fmt.Fprintf(&b, "    %s[\"%s\"]\n", id, vertex)
+		}
+		b.WriteString("  end\n")
+	}
+	for _, edge := range g.Edges() {
+		fmt.Fprintf(&b, "  %s --> %s\n", ids[edge.From], ids[edge.To])
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// jsonVertex is a vertex in the JSON export.
+type jsonVertex struct {
+	ID      string `json:"id"`
+	Project string `json:"project"`
+	Stack   string `json:"stack"`
+	Stage   int    `json:"stage"`
+}
+
+func (g *Graph) writeJSON(w io.Writer) error {
+	stages := g.Stages()
+	export := struct {
+		Vertices []jsonVertex `json:"vertices"`
+		Edges    []Edge       `json:"edges"`
+		Stages   [][]string   `json:"stages"`
+	}{
+		Vertices: []jsonVertex{},
+		Edges:    g.Edges(),
+		Stages:   stages,
+	}
+	if export.Edges == nil {
+		export.Edges = []Edge{}
+	}
+	for i, stage := range stages {
+		for _, vertex := range stage {
+			project, stack, _ := strings.Cut(vertex, ":")
+			export.Vertices = append(export.Vertices, jsonVertex{
+				ID:      vertex,
+				Project: project,
+				Stack:   stack,
+				Stage:   i + 1,
+			})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(export)
+}
@@ -2,6 +2,7 @@
 package graph
 
 import (
+	"errors"
 	"fmt"
 	"sort"
 
@@ -23,7 +24,37 @@ func containsStack(stacks []string, stack string) bool {
 	return false
 }
 
-func GetExecutionGroups(projects []p.Project) ([][]string, error) {
+// Graph is the set of project:stack vertices and the dependencies between them.
+type Graph struct {
+	// Vertices holds every project:stack vertex in topological order.
+	Vertices []string
+	// Dependencies maps each vertex to the vertices it depends on.
+	Dependencies map[string][]string
+}
+
+// Build creates the dependency graph for the configured projects and verifies it can be ordered.
+func Build(projects []p.Project) (*Graph, error) {
+	g, dependencies, err := buildDependencies(projects)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get vertices in topological order
+	order, err := graph.StableTopologicalSort(g, func(a, b string) bool { return a < b })
+	if err != nil {
+		// Name the offending cycles rather than reporting a generic sort failure
+		if cycles := findCycles(g, dependencies); len(cycles) > 0 {
+			return nil, &CycleError{Cycles: cycles}
+		}
+		return nil, fmt.Errorf("failed to perform topological sort: %w", err)
+	}
+
+	return &Graph{Vertices: order, Dependencies: dependencies}, nil
+}
+
+// buildDependencies adds a vertex for every project:stack and an edge for every dependency,
+// returning the graph and each vertex's dependencies. The graph may still contain cycles.
+func buildDependencies(projects []p.Project) (graph.Graph[string, string], map[string][]string, error) {
 	// Create a directed graph
 	g := graph.New(graph.StringHash, graph.Directed())
 
@@ -32,7 +63,7 @@ func GetExecutionGroups(projects []p.Project) ([][]string, error) {
 
 	// First, create a map of all valid project-stack combinations and merge duplicate projects
 	validStacks := make(map[string][]string)
-	projectDeps := make(map[string][]string) // Track merged dependencies
+	projectDeps := make(map[string][]p.Dependency) // Track merged dependencies
 
 	for _, project := range projects {
 		// Convert project.Stacks to []string (stack names)
@@ -59,16 +90,16 @@ func GetExecutionGroups(projects []p.Project) ([][]string, error) {
 			validStacks[project.Name] = mergedStacks
 
 			// Merge dependencies
-			depMap := make(map[string]bool)
+			depMap := make(map[string]p.Dependency)
 			for _, dep := range projectDeps[project.Name] {
-				depMap[dep] = true
+				depMap[dep.String()] = dep
 			}
 			for _, dep := range project.DependsOn {
-				depMap[dep] = true
+				depMap[dep.String()] = dep
 			}
 
-			var mergedDeps []string
-			for dep := range depMap {
+			var mergedDeps []p.Dependency
+			for _, dep := range depMap {
 				mergedDeps = append(mergedDeps, dep)
 			}
 			projectDeps[project.Name] = mergedDeps
@@ -83,7 +114,7 @@ func GetExecutionGroups(projects []p.Project) ([][]string, error) {
 		for _, stack := range stacks {
 			vertex := vertexID(projectName, stack)
 			if err := g.AddVertex(vertex); err != nil {
-				return nil, fmt.Errorf("failed to add vertex %s: %w", vertex, err)
+				return nil, nil, fmt.Errorf("failed to add vertex %s: %w", vertex, err)
 			}
 		}
 	}
@@ -95,26 +126,71 @@ func GetExecutionGroups(projects []p.Project) ([][]string, error) {
 			currentVertex := vertexID(projectName, stack)
 			dependencies[currentVertex] = []string{}
 
-			// Add edges for each dependency, but only if the dependency exists in the same stack
+			// Add edges for each dependency. Dependencies implied by a matching stack name are
+			// only added if the dependency has that stack; explicitly named stacks must exist.
 			for _, dep := range deps {
-				// Check if the dependency exists in this stack
-				if containsStack(validStacks[dep], stack) {
-					depVertex := vertexID(dep, stack)
-					if err := g.AddEdge(depVertex, currentVertex); err != nil {
-						return nil, fmt.Errorf("failed to add edge from %s to %s: %w", depVertex, currentVertex, err)
+				depStack, explicit := dep.StackFor(stack)
+				if !containsStack(validStacks[dep.Project], depStack) {
+					if explicit {
+						return nil, nil, fmt.Errorf("stack %s depends on unknown stack %s", currentVertex, vertexID(dep.Project, depStack))
 					}
-					dependencies[currentVertex] = append(dependencies[currentVertex], depVertex)
+					continue
 				}
+				depVertex := vertexID(dep.Project, depStack)
+				if err := g.AddEdge(depVertex, currentVertex); err != nil {
+					if errors.Is(err, graph.ErrEdgeAlreadyExists) {
+						continue
+					}
+					return nil, nil, fmt.Errorf("failed to add edge from %s to %s: %w", depVertex, currentVertex, err)
+				}
+				dependencies[currentVertex] = append(dependencies[currentVertex], depVertex)
 			}
 		}
 	}
 
-	// Get vertices in topological order
-	order, err := graph.TopologicalSort(g)
-	if err != nil {
-		return nil, fmt.Errorf("failed to perform topological sort: %w", err)
+	// Stacks that read another stack's outputs must run after it
+	for _, project := range projects {
+		for _, sc := range project.Stacks {
+			currentVertex := vertexID(project.Name, sc.Name)
+			for _, ref := range sc.ConfigFrom {
+				depProject, depStack := ref.Source(sc.Name)
+				if !containsStack(validStacks[depProject], depStack) {
+					return nil, nil, fmt.Errorf("stack %s reads outputs from unknown stack %s", currentVertex, vertexID(depProject, depStack))
+				}
+				depVertex := vertexID(depProject, depStack)
+				if err := g.AddEdge(depVertex, currentVertex); err != nil {
+					if errors.Is(err, graph.ErrEdgeAlreadyExists) {
+						continue
+					}
+					return nil, nil, fmt.Errorf("failed to add edge from %s to %s: %w", depVertex, currentVertex, err)
+				}
+				dependencies[currentVertex] = append(dependencies[currentVertex], depVertex)
+			}
+		}
 	}
 
+	return g, dependencies, nil
+}
+
+// Dependents returns the reverse of Dependencies: each vertex mapped to the vertices that depend on it.
+func (g *Graph) Dependents() map[string][]string {
+	dependents := make(map[string][]string)
+	for _, vertex := range g.Vertices {
+		for _, dep := range g.Dependencies[vertex] {
+			dependents[dep] = append(dependents[dep], vertex)
+		}
+	}
+	for _, vertices := range dependents {
+		sort.Strings(vertices)
+	}
+	return dependents
+}
+
+// Stages groups the vertices into stages where every vertex only depends on vertices in earlier stages.
+func (g *Graph) Stages() [][]string {
+	order := g.Vertices
+	dependencies := g.Dependencies
+
 	// Create concurrent execution groups
 	var executionGroups [][]string
 	processed := make(map[string]bool)
@@ -154,5 +230,14 @@ func GetExecutionGroups(projects []p.Project) ([][]string, error) {
 		executionGroups = append(executionGroups, currentGroup)
 	}
 
-	return executionGroups, nil
+	return executionGroups
+}
+
+// GetExecutionGroups returns the stages of the dependency graph for the configured projects.
+func GetExecutionGroups(projects []p.Project) ([][]string, error) {
+	g, err := Build(projects)
+	if err != nil {
+		return nil, err
+	}
+	return g.Stages(), nil
 }
@@ -0,0 +1,162 @@
+// pkg/graph/select.go - Restrict the dependency graph to a subset of stacks
+package graph
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Selection restricts a graph to part of its vertices. The zero value selects everything.
+type Selection struct {
+	// Stacks are glob patterns matched against stack names. When set, only matching stacks are kept.
+	Stacks []string
+	// Targets are the project or project:stack names to run.
+	Targets []string
+	// IncludeDependencies adds every stack the targets depend on, directly or transitively.
+	IncludeDependencies bool
+	// IncludeDependents adds every stack that depends on the targets, directly or transitively.
+	IncludeDependents bool
+}
+
+// matches reports whether a vertex is named by a target, which is either a project or a project:stack.
+func matches(target string, vertex string) bool {
+	if strings.Contains(target, ":") {
+		return target == vertex
+	}
+	project, _, _ := strings.Cut(vertex, ":")
+	return target == project
+}
+
+// matchesStack reports whether a vertex's stack name matches any of the glob patterns.
+func matchesStack(patterns []string, vertex string) (bool, error) {
+	_, stack, _ := strings.Cut(vertex, ":")
+	for _, pattern := range patterns {
+		ok, err := path.Match(pattern, stack)
+		if err != nil {
+			return false, fmt.Errorf("invalid stack pattern %q: %w", pattern, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Select returns the subgraph chosen by the selection. The stack filter limits which
+// stacks can be targets; with no targets, every matching stack is a target. Dependents are
+// only followed within the filter, but dependencies are followed through the whole graph,
+// so a stack outside the filter that a selected stack needs, such as a shared network
+// stack, is still included. Ordering between the selected vertices is kept even when the
+// vertices that connected them are pruned.
+func (g *Graph) Select(sel Selection) (*Graph, error) {
+	if len(sel.Stacks) == 0 && len(sel.Targets) == 0 {
+		return g, nil
+	}
+
+	filtered := g
+	if len(sel.Stacks) > 0 {
+		keep := make(map[string]bool)
+		for _, vertex := range g.Vertices {
+			ok, err := matchesStack(sel.Stacks, vertex)
+			if err != nil {
+				return nil, err
+			}
+			keep[vertex] = ok
+		}
+		filtered = g.subgraph(keep)
+		if len(filtered.Vertices) == 0 {
+			return nil, fmt.Errorf("stack filter %s does not match any stack", strings.Join(sel.Stacks, ", "))
+		}
+	}
+
+	keep := make(map[string]bool)
+	if len(sel.Targets) == 0 {
+		for _, vertex := range filtered.Vertices {
+			keep[vertex] = true
+		}
+	}
+	for _, target := range sel.Targets {
+		found := false
+		for _, vertex := range filtered.Vertices {
+			if matches(target, vertex) {
+				keep[vertex] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("target %q does not match any project or stack", target)
+		}
+	}
+
+	// Walk outwards from the targets
+	targets := make([]string, 0, len(keep))
+	for _, vertex := range filtered.Vertices {
+		if keep[vertex] {
+			targets = append(targets, vertex)
+		}
+	}
+	if sel.IncludeDependencies {
+		collect(targets, g.Dependencies, keep)
+	}
+	if sel.IncludeDependents {
+		collect(targets, filtered.Dependents(), keep)
+	}
+
+	return g.subgraph(keep), nil
+}
+
+// collect adds every vertex reachable from the starting vertices through edges to keep.
+func collect(start []string, edges map[string][]string, keep map[string]bool) {
+	queue := append([]string(nil), start...)
+	for len(queue) > 0 {
+		vertex := queue[0]
+		queue = queue[1:]
+		for _, next := range edges[vertex] {
+			if !keep[next] {
+				keep[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+}
+
+// subgraph returns the graph restricted to the kept vertices. A kept vertex depends on the
+// nearest kept vertices upstream of it, looking through any vertices that were pruned.
+func (g *Graph) subgraph(keep map[string]bool) *Graph {
+	sub := &Graph{Dependencies: make(map[string][]string)}
+
+	// nearest memoises the kept vertices found upstream of each vertex
+	nearest := make(map[string][]string)
+	var upstream func(vertex string) []string
+	upstream = func(vertex string) []string {
+		if deps, ok := nearest[vertex]; ok {
+			return deps
+		}
+		seen := make(map[string]bool)
+		var deps []string
+		for _, dep := range g.Dependencies[vertex] {
+			candidates := []string{dep}
+			if !keep[dep] {
+				candidates = upstream(dep)
+			}
+			for _, candidate := range candidates {
+				if !seen[candidate] {
+					seen[candidate] = true
+					deps = append(deps, candidate)
+				}
+			}
+		}
+		nearest[vertex] = deps
+		return deps
+	}
+
+	for _, vertex := range g.Vertices {
+		if !keep[vertex] {
+			continue
+		}
+		sub.Vertices = append(sub.Vertices, vertex)
+		sub.Dependencies[vertex] = upstream(vertex)
+	}
+	return sub
+}
@@ -0,0 +1,236 @@
+package graph
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	p "github.com/jaxxstorm/pedloy/pkg/project"
+)
+
+// testGraph builds the graph used by the selection tests:
+//
+//	network:shared <- app:dev  <- web:dev
+//	network:dev    <-/
+//	network:prod   <- app:prod <- web:prod
+//	network:shared <-/
+func testGraph(t *testing.T) *Graph {
+	t.Helper()
+	projects := []p.Project{
+		{Name: "network", Stacks: p.Stacks{{Name: "shared"}, {Name: "dev"}, {Name: "prod"}}},
+		{
+			Name:   "app",
+			Stacks: p.Stacks{{Name: "dev"}, {Name: "prod"}},
+			DependsOn: []p.Dependency{
+				{Project: "network"},
+				{Project: "network", Stack: "shared"},
+			},
+		},
+		{Name: "web", Stacks: p.Stacks{{Name: "dev"}, {Name: "prod"}}, DependsOn: []p.Dependency{{Project: "app"}}},
+	}
+	g, err := Build(projects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// checkOrder fails if a selected vertex comes before one it depends on.
+func checkOrder(t *testing.T, g *Graph) {
+	t.Helper()
+	position := make(map[string]int, len(g.Vertices))
+	for i, vertex := range g.Vertices {
+		position[vertex] = i
+	}
+	for _, vertex := range g.Vertices {
+		for _, dep := range g.Dependencies[vertex] {
+			i, ok := position[dep]
+			if !ok {
+				t.Errorf("%s depends on %s, which was not selected", vertex, dep)
+			} else if i > position[vertex] {
+				t.Errorf("%s comes before its dependency %s in %v", vertex, dep, g.Vertices)
+			}
+		}
+	}
+}
+
+func sorted(vertices []string) []string {
+	vertices = append([]string(nil), vertices...)
+	sort.Strings(vertices)
+	return vertices
+}
+
+func TestSelectTargets(t *testing.T) {
+	tests := []struct {
+		name string
+		sel  Selection
+		want []string
+		// deps checks the dependencies of some selected vertices
+		deps map[string][]string
+	}{
+		{
+			name: "project target",
+			sel:  Selection{Targets: []string{"app"}},
+			want: []string{"app:dev", "app:prod"},
+			deps: map[string][]string{"app:dev": nil},
+		},
+		{
+			name: "stack target",
+			sel:  Selection{Targets: []string{"app:dev"}},
+			want: []string{"app:dev"},
+		},
+		{
+			name: "with dependencies",
+			sel:  Selection{Targets: []string{"web:dev"}, IncludeDependencies: true},
+			want: []string{"app:dev", "network:dev", "network:shared", "web:dev"},
+			deps: map[string][]string{"app:dev": {"network:dev", "network:shared"}, "web:dev": {"app:dev"}},
+		},
+		{
+			name: "with dependents",
+			sel:  Selection{Targets: []string{"network:shared"}, IncludeDependents: true},
+			want: []string{"app:dev", "app:prod", "network:shared", "web:dev", "web:prod"},
+		},
+		{
+			name: "with dependencies and dependents",
+			sel:  Selection{Targets: []string{"app:prod"}, IncludeDependencies: true, IncludeDependents: true},
+			want: []string{"app:prod", "network:prod", "network:shared", "web:prod"},
+		},
+		{
+			name: "ordering kept through pruned vertices",
+			sel:  Selection{Targets: []string{"web:dev", "network:dev"}},
+			want: []string{"network:dev", "web:dev"},
+			deps: map[string][]string{"web:dev": {"network:dev"}, "network:dev": nil},
+		},
+		{
+			name: "pruned vertex with several kept upstreams",
+			sel:  Selection{Targets: []string{"web:dev", "network"}},
+			want: []string{"network:dev", "network:prod", "network:shared", "web:dev"},
+			deps: map[string][]string{"web:dev": {"network:dev", "network:shared"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := testGraph(t).Select(tt.sel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sorted(g.Vertices); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selected %v, want %v", got, tt.want)
+			}
+			checkOrder(t, g)
+			for vertex, want := range tt.deps {
+				if got := sorted(g.Dependencies[vertex]); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s depends on %v, want %v", vertex, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectStackFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		sel  Selection
+		want []string
+		deps map[string][]string
+	}{
+		{
+			name: "filter only",
+			sel:  Selection{Stacks: []string{"dev"}},
+			want: []string{"app:dev", "network:dev", "web:dev"},
+			deps: map[string][]string{"app:dev": {"network:dev"}},
+		},
+		{
+			name: "glob patterns",
+			sel:  Selection{Stacks: []string{"d*", "sh?red"}},
+			want: []string{"app:dev", "network:dev", "network:shared", "web:dev"},
+		},
+		{
+			name: "dependencies outside the filter are followed",
+			sel:  Selection{Stacks: []string{"dev"}, IncludeDependencies: true},
+			want: []string{"app:dev", "network:dev", "network:shared", "web:dev"},
+			deps: map[string][]string{"app:dev": {"network:dev", "network:shared"}},
+		},
+		{
+			name: "targets limited to the filter",
+			sel:  Selection{Stacks: []string{"prod"}, Targets: []string{"app"}},
+			want: []string{"app:prod"},
+		},
+		{
+			name: "target dependencies across the filter",
+			sel:  Selection{Stacks: []string{"prod"}, Targets: []string{"web"}, IncludeDependencies: true},
+			want: []string{"app:prod", "network:prod", "network:shared", "web:prod"},
+		},
+		{
+			name: "dependents stay within the filter",
+			sel:  Selection{Stacks: []string{"prod"}, Targets: []string{"network"}, IncludeDependents: true},
+			want: []string{"app:prod", "network:prod", "web:prod"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := testGraph(t).Select(tt.sel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sorted(g.Vertices); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selected %v, want %v", got, tt.want)
+			}
+			checkOrder(t, g)
+			for vertex, want := range tt.deps {
+				if got := sorted(g.Dependencies[vertex]); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s depends on %v, want %v", vertex, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectEverything(t *testing.T) {
+	g := testGraph(t)
+	selected, err := g.Select(Selection{IncludeDependencies: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected != g {
+		t.Error("an empty selection did not return the whole graph")
+	}
+}
+
+func TestSelectErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		sel     Selection
+		wantErr string
+	}{
+		{
+			name:    "unknown target",
+			sel:     Selection{Targets: []string{"missing"}},
+			wantErr: `target "missing" does not match any project or stack`,
+		},
+		{
+			name:    "target outside the filter",
+			sel:     Selection{Stacks: []string{"prod"}, Targets: []string{"app:dev"}},
+			wantErr: `target "app:dev" does not match any project or stack`,
+		},
+		{
+			name:    "filter matches nothing",
+			sel:     Selection{Stacks: []string{"staging", "qa"}},
+			wantErr: "stack filter staging, qa does not match any stack",
+		},
+		{
+			name:    "invalid pattern",
+			sel:     Selection{Stacks: []string{"[dev"}},
+			wantErr: `invalid stack pattern "[dev"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := testGraph(t).Select(tt.sel)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+// pkg/project/env.go - Resolve stack environment variables from env files and interpolation
+
+package project
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Resolved is an effective env or config value for a stack and the level that set it.
+type Resolved struct {
+	Value  string
+	Secret bool
+	// External is set when the value was read from an env file or the calling environment
+	// rather than written in the configuration, so it may hold a credential that is not
+	// marked secret.
+	External bool
+	// Source names the level the value came from, e.g. "defaults", "project" or "stack envFile .env".
+	Source string
+}
+
+// ResolveStackEnv returns the effective environment variables for the named stack and
+// where each came from. Levels are applied in order, later ones taking precedence: the
+// global defaults, the project's env file, the project's env, the stack's env file and
+// the stack's env. ${VAR} and ${VAR:-default} in values are expanded from the variables
+// resolved so far and then from the calling environment; $$ is a literal $. A value that
+// references a secret variable is secret too, and one read from an env file or that
+// references the calling environment is external. AWS_PROFILE is set to the stack's
+// effective AWS profile, see EffectiveAWSProfile.
+func (p Project) ResolveStackEnv(stack string) (map[string]Resolved, error) {
+	r := &envResolver{env: make(map[string]Resolved)}
+
+	// apply expands an env map into env, in a stable order so errors are reported deterministically
+	apply := func(values EnvMap, source string) error {
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			value, err := r.expand(values[k].Value)
+			if err != nil {
+				return fmt.Errorf("%s env %s: %w", source, k, err)
+			}
+			value.Secret = value.Secret || values[k].Secret
+			value.Source = source
+			r.env[k] = value
+		}
+		return nil
+	}
+	// load reads an env file into env. Later lines in the file may refer to earlier ones.
+	load := func(file EnvFile, source string) error {
+		if file.Path == "" {
+			return nil
+		}
+		return loadEnvFile(file.Path, r.expand, func(key string, value Resolved) {
+			value.Secret = value.Secret || file.Secret
+			value.External = true
+			value.Source = source + " envFile " + file.Path
+			r.env[key] = value
+		})
+	}
+
+	sc, _ := p.Stack(stack)
+	if err := apply(p.Defaults.Env, "defaults"); err != nil {
+		return nil, err
+	}
+	if err := load(p.EnvFile, "project"); err != nil {
+		return nil, err
+	}
+	if err := apply(p.Env, "project"); err != nil {
+		return nil, err
+	}
+	if err := load(sc.EnvFile, "stack"); err != nil {
+		return nil, err
+	}
+	if err := apply(sc.Env, "stack"); err != nil {
+		return nil, err
+	}
+	if profile := p.EffectiveAWSProfile(stack, r.env["AWS_PROFILE"]); profile.Value != "" {
+		r.env["AWS_PROFILE"] = profile
+	}
+	return r.env, nil
+}
+
+// StackEnv returns the effective environment variables for the named stack. See ResolveStackEnv.
+func (p Project) StackEnv(stack string) (EnvMap, error) {
+	resolved, err := p.ResolveStackEnv(stack)
+	if err != nil {
+		return nil, err
+	}
+	env := make(EnvMap, len(resolved))
+	for k, v := range resolved {
+		env[k] = EnvValue{Value: v.Value, Secret: v.Secret}
+	}
+	return env, nil
+}
+
+// envResolver expands values against the variables resolved so far and then the calling
+// environment, keeping track of whether a secret or external variable was referenced.
+type envResolver struct {
+	env map[string]Resolved
+	ref Resolved
+}
+
+func (r *envResolver) lookup(name string) (string, bool) {
+	if value, ok := r.env[name]; ok {
+		r.ref.Secret = r.ref.Secret || value.Secret
+		r.ref.External = r.ref.External || value.External
+		return value.Value, true
+	}
+	value, ok := os.LookupEnv(name)
+	r.ref.External = r.ref.External || ok
+	return value, ok
+}
+
+// expand interpolates s. The result is secret or external if s referenced a secret or
+// external variable; Source is left for the caller to set.
+func (r *envResolver) expand(s string) (Resolved, error) {
+	r.ref = Resolved{}
+	value, err := interpolate(s, r.lookup)
+	r.ref.Value = value
+	return r.ref, err
+}
+
+// interpolate expands ${VAR} and ${VAR:-default} references in s. A variable that is not
+// set and has no default is an error, so a missing secret fails fast instead of being
+// passed on as an empty string.
+func interpolate(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference in %q", s)
+			}
+			ref := s[i+2 : i+2+end]
+			name, def, hasDefault := strings.Cut(ref, ":-")
+			if name == "" {
+				return "", fmt.Errorf("empty variable reference in %q", s)
+			}
+			value, ok := lookup(name)
+			switch {
+			case ok && value != "":
+				b.WriteString(value)
+			case hasDefault:
+				b.WriteString(def)
+			case ok:
+			default:
+				return "", fmt.Errorf("variable %s is not set", name)
+			}
+			i += 2 + end
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+// loadEnvFile reads a dotenv file and calls set for every variable in order. Lines are
+// KEY=VALUE, optionally prefixed with export; blank lines and lines starting with # are
+// ignored. Double-quoted values support \n escapes and interpolation, single-quoted values
+// are used literally, and unquoted values are trimmed and interpolated. Any value may be
+// followed by a " #" comment. expand interpolates a value, see envResolver.expand.
+func loadEnvFile(path string, expand func(string) (Resolved, error), set func(key string, value Resolved)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open env file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, raw, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		raw, quote, err := unquote(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+
+		var value Resolved
+		switch quote {
+		case '\'':
+			value = Resolved{Value: raw}
+		case '"':
+			quoted := strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(raw)
+			if value, err = expand(quoted); err != nil {
+				return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+		default:
+			if i := strings.Index(raw, " #"); i >= 0 {
+				raw = strings.TrimSpace(raw[:i])
+			}
+			if value, err = expand(raw); err != nil {
+				return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+		}
+		set(key, value)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read env file %s: %w", path, err)
+	}
+	return nil
+}
+
+// unquote returns the text between the quotes of a value that starts with ' or ", and the
+// quote used, dropping a comment after the closing quote. Inside double quotes a backslash
+// escapes the next character. An unquoted value is returned unchanged with a zero quote.
+func unquote(raw string) (string, byte, error) {
+	if raw == "" || (raw[0] != '\'' && raw[0] != '"') {
+		return raw, 0, nil
+	}
+	quote := raw[0]
+	for i := 1; i < len(raw); i++ {
+		if quote == '"' && raw[i] == '\\' {
+			i++
+			continue
+		}
+		if raw[i] != quote {
+			continue
+		}
+		if rest := strings.TrimSpace(raw[i+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", 0, fmt.Errorf("unexpected %q after quoted value", rest)
+		}
+		return raw[1:i], quote, nil
+	}
+	return "", 0, fmt.Errorf("unterminated %c quote", quote)
+}
@@ -0,0 +1,165 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	vars := map[string]string{
+		"NAME":  "pedloy",
+		"EMPTY": "",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr string
+	}{
+		{in: "plain", want: "plain"},
+		{in: "${NAME}", want: "pedloy"},
+		{in: "a-${NAME}-b", want: "a-pedloy-b"},
+		{in: "$$", want: "$"},
+		{in: "cost $$5 for ${NAME}", want: "cost $5 for pedloy"},
+		{in: "$${NAME}", want: "${NAME}"},
+		{in: "$NAME", want: "$NAME"},
+		{in: "trailing $", want: "trailing $"},
+		{in: "${UNSET:-fallback}", want: "fallback"},
+		{in: "${UNSET:-}", want: ""},
+		{in: "${EMPTY:-fallback}", want: "fallback"},
+		{in: "${EMPTY}", want: ""},
+		{in: "${NAME:-fallback}", want: "pedloy"},
+		{in: "${UNSET}", wantErr: "variable UNSET is not set"},
+		{in: "${NAME", wantErr: "unterminated variable reference"},
+		{in: "${}", wantErr: "empty variable reference"},
+	}
+	for _, tt := range tests {
+		got, err := interpolate(tt.in, lookup)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("interpolate(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("interpolate(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("interpolate(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	t.Setenv("PEDLOY_TEST_HOST", "example.com")
+
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:    "plain values",
+			content: "A=1\nB = two words \n",
+			want:    map[string]string{"A": "1", "B": "two words"},
+		},
+		{
+			name:    "export, blank lines and comments",
+			content: "# comment\n\nexport A=1\n  # indented comment\nB=2 # trailing comment\n",
+			want:    map[string]string{"A": "1", "B": "2"},
+		},
+		{
+			name:    "hash without a space is part of the value",
+			content: "A=abc#def\n",
+			want:    map[string]string{"A": "abc#def"},
+		},
+		{
+			name:    "double quotes",
+			content: `A="quoted value"` + "\n" + `B="line1\nline2"` + "\n" + `C="say \"hi\" \\ done"` + "\n" + `D="# not a comment"` + "\n",
+			want:    map[string]string{"A": "quoted value", "B": "line1\nline2", "C": `say "hi" \ done`, "D": "# not a comment"},
+		},
+		{
+			name:    "quoted values with trailing comments",
+			content: `A="quoted value" # comment` + "\n" + `B='x' # c` + "\n" + `C="v"#c` + "\n",
+			want:    map[string]string{"A": "quoted value", "B": "x", "C": "v"},
+		},
+		{
+			name:    "single quotes are literal",
+			content: `A='${PEDLOY_TEST_HOST} \n $$'` + "\n",
+			want:    map[string]string{"A": `${PEDLOY_TEST_HOST} \n $$`},
+		},
+		{
+			name:    "interpolation from earlier lines and the environment",
+			content: "HOST=${PEDLOY_TEST_HOST}\nURL=\"https://${HOST}/v1\"\nPORT=${PORT:-443}\n",
+			want:    map[string]string{"HOST": "example.com", "URL": "https://example.com/v1", "PORT": "443"},
+		},
+		{
+			name:    "empty values",
+			content: "A=\nB=\"\"\nC=''\n",
+			want:    map[string]string{"A": "", "B": "", "C": ""},
+		},
+		{
+			name:    "missing equals",
+			content: "A=1\nNOT_A_PAIR\n",
+			wantErr: ":2: expected KEY=VALUE",
+		},
+		{
+			name:    "unset variable",
+			content: "A=${PEDLOY_TEST_UNSET}\n",
+			wantErr: ":1: variable PEDLOY_TEST_UNSET is not set",
+		},
+		{
+			name:    "unterminated quote",
+			content: `A="open` + "\n",
+			wantErr: `:1: unterminated " quote`,
+		},
+		{
+			name:    "text after a closing quote",
+			content: `A="a" b` + "\n",
+			wantErr: `:1: unexpected "b" after quoted value`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".env")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			r := &envResolver{env: make(map[string]Resolved)}
+			got := make(map[string]string)
+			err := loadEnvFile(path, r.expand, func(key string, value Resolved) {
+				r.env[key] = value
+				got[key] = value.Value
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loaded %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadEnvFileMissing(t *testing.T) {
+	err := loadEnvFile(filepath.Join(t.TempDir(), "missing.env"), (&envResolver{}).expand, func(string, Resolved) {})
+	if err == nil || !strings.Contains(err.Error(), "failed to open env file") {
+		t.Fatalf("error = %v, want a failure to open the file", err)
+	}
+}
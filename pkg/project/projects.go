@@ -2,88 +2,429 @@
 
 package project
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
 
 type StackConfig struct {
-	Name string            `yaml:"name"`
-	Env  map[string]string `yaml:"env,omitempty"`
+	Name string `yaml:"name"`
+	Env  EnvMap `yaml:"env,omitempty"`
+	// EnvFile is a dotenv file loaded into the stack's env, after the project's env file.
+	EnvFile EnvFile `yaml:"envFile,omitempty"`
+	// Config sets Pulumi config on this stack, overriding the project's config.
+	Config ConfigMap `yaml:"config,omitempty"`
+	// ConfigFrom sets config keys on this stack from the outputs of other stacks.
+	ConfigFrom []OutputRef `yaml:"configFrom,omitempty"`
+	// AWSProfile overrides the project's AWS profile for this stack.
+	AWSProfile string `yaml:"aws_profile,omitempty"`
+	// Timeout bounds how long an operation on this stack may take, overriding the project's.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// RetryPolicy overrides the global retry settings for this stack.
+	RetryPolicy `yaml:",inline"`
+}
+
+// ConfigValue is a Pulumi config value. Values written as `secure: <value>` are stored as secrets.
+type ConfigValue struct {
+	Value  string
+	Secret bool
+}
+
+func (c *ConfigValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// Plain values are used as-is
+	var value string
+	if err := unmarshal(&value); err == nil {
+		*c = ConfigValue{Value: value}
+		return nil
+	}
+
+	// Secrets are written as a map with a single secure key
+	var secure map[string]string
+	if err := unmarshal(&secure); err == nil {
+		if value, ok := secure["secure"]; ok && len(secure) == 1 {
+			*c = ConfigValue{Value: value, Secret: true}
+			return nil
+		}
+	}
+	return fmt.Errorf("config values must be a string or a map with a single 'secure' key")
+}
+
+// ConfigMap maps Pulumi config keys, e.g. "aws:region", to their values.
+type ConfigMap map[string]ConfigValue
+
+// EnvValue is an environment variable value. Values marked secret are never shown in
+// logs, engine output or reports.
+type EnvValue struct {
+	Value  string `yaml:"value"`
+	Secret bool   `yaml:"secret,omitempty"`
+}
+
+func (e *EnvValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// Plain values are used as-is
+	var value string
+	if err := unmarshal(&value); err == nil {
+		*e = EnvValue{Value: value}
+		return nil
+	}
+
+	type rawEnvValue EnvValue
+	var raw rawEnvValue
+	if err := unmarshal(&raw); err != nil {
+		return fmt.Errorf("env values must be a string or a map with 'value' and 'secret' keys")
+	}
+	*e = EnvValue(raw)
+	return nil
+}
+
+// EnvFile is a dotenv file loaded into a stack's env. It is written as a path, or as a map
+// with a path and secret: true to treat every value in the file as a secret.
+type EnvFile struct {
+	Path   string `yaml:"path"`
+	Secret bool   `yaml:"secret,omitempty"`
+}
+
+func (e *EnvFile) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// A plain string is the path
+	var path string
+	if err := unmarshal(&path); err == nil {
+		*e = EnvFile{Path: path}
+		return nil
+	}
+
+	type rawEnvFile EnvFile
+	var raw rawEnvFile
+	if err := unmarshal(&raw); err != nil || raw.Path == "" {
+		return fmt.Errorf("envFile must be a path or a map with 'path' and 'secret' keys")
+	}
+	*e = EnvFile(raw)
+	return nil
+}
+
+// EnvMap maps environment variable names to their values.
+type EnvMap map[string]EnvValue
+
+// OutputRef sets a config key on a stack from an output of an upstream stack.
+type OutputRef struct {
+	// Key is the config key to set, e.g. "vpcId" or "aws:region".
+	Key string `yaml:"key"`
+	// Stack is the upstream project:stack. A bare project name means the stack with the same name.
+	Stack string `yaml:"stack"`
+	// Output is the output to read. Dots select nested values, e.g. "vpc.id".
+	Output string `yaml:"output"`
+	// Secret stores the config value as a secret even if the output is not one.
+	Secret bool `yaml:"secret,omitempty"`
+}
+
+// Source returns the upstream project and stack names for the stack named stack.
+func (r OutputRef) Source(stack string) (string, string) {
+	if project, upstream, ok := strings.Cut(r.Stack, ":"); ok {
+		return project, upstream
+	}
+	return r.Stack, stack
+}
+
+func (c *StackConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// A plain string is just the stack name
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*c = StackConfig{Name: name}
+		return nil
+	}
+
+	// Otherwise decode the full object, using an alias to avoid recursing into this method
+	type rawStackConfig StackConfig
+	var raw rawStackConfig
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	// Name is required
+	if raw.Name == "" {
+		return fmt.Errorf("stack config missing required 'name' field")
+	}
+	*c = StackConfig(raw)
+	return nil
 }
 
 type Stacks []StackConfig
 
 func (s *Stacks) UnmarshalYAML(unmarshal func(interface{}) error) error {
-	// Try as []string first
-	var names []string
-	if err := unmarshal(&names); err == nil {
-		*s = make([]StackConfig, len(names))
-		for i, n := range names {
-			(*s)[i] = StackConfig{Name: n}
+	// Each entry is either a stack name or a stack config object
+	var configs []StackConfig
+	if err := unmarshal(&configs); err != nil {
+		return fmt.Errorf("stacks must be a list of strings or a list of stack config objects: %w", err)
+	}
+	*s = configs
+	return nil
+}
+
+// Stack returns the config for the named stack, if the project defines it.
+func (p Project) Stack(name string) (StackConfig, bool) {
+	for _, sc := range p.Stacks {
+		if sc.Name == name {
+			return sc, true
 		}
+	}
+	return StackConfig{}, false
+}
+
+// Dependency is an entry in a project's dependsOn list. It is written as a project name,
+// which depends on the stack with the same name, as "project:stack" to depend on one
+// specific stack, or as an object with a stack mapping.
+type Dependency struct {
+	Project string `yaml:"project"`
+	// Stack pins the dependency to a single stack of the project.
+	Stack string `yaml:"stack,omitempty"`
+	// Stacks maps this project's stack names to the stack names of the dependency.
+	Stacks map[string]string `yaml:"stacks,omitempty"`
+}
+
+func (d *Dependency) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// A plain string is either "project" or "project:stack"
+	var name string
+	if err := unmarshal(&name); err == nil {
+		project, stack, _ := strings.Cut(name, ":")
+		*d = Dependency{Project: project, Stack: stack}
 		return nil
 	}
 
-	// Try as []map[string]interface{} to support env
-	var rawConfigs []map[string]interface{}
-	if err := unmarshal(&rawConfigs); err == nil {
-		configs := make([]StackConfig, len(rawConfigs))
-		for i, raw := range rawConfigs {
-			// Name is required
-			name, ok := raw["name"].(string)
-			if !ok {
-				return fmt.Errorf("stack config missing required 'name' field")
-			}
-			configs[i].Name = name
-
-			// Env is optional
-			if envRaw, ok := raw["env"]; ok {
-				envMap := make(map[string]string)
-				switch envTyped := envRaw.(type) {
-				case map[interface{}]interface{}:
-					for k, v := range envTyped {
-						ks, kOk := k.(string)
-						vs, vOk := v.(string)
-						if kOk && vOk {
-							envMap[ks] = vs
-						}
-					}
-				case map[string]interface{}:
-					for k, v := range envTyped {
-						if vs, vOk := v.(string); vOk {
-							envMap[k] = vs
-						}
-					}
-				}
-				configs[i].Env = envMap
+	type rawDependency Dependency
+	var raw rawDependency
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	if raw.Project == "" {
+		return fmt.Errorf("dependency missing required 'project' field")
+	}
+	if raw.Stack != "" && len(raw.Stacks) > 0 {
+		return fmt.Errorf("dependency on %q cannot set both 'stack' and 'stacks'", raw.Project)
+	}
+	*d = Dependency(raw)
+	return nil
+}
+
+// StackFor returns the stack of the dependency that the named stack depends on, and
+// whether it was named explicitly rather than implied by a matching stack name.
+func (d Dependency) StackFor(stack string) (string, bool) {
+	if d.Stack != "" {
+		return d.Stack, true
+	}
+	if mapped, ok := d.Stacks[stack]; ok {
+		return mapped, true
+	}
+	return stack, false
+}
+
+// String returns the dependency as it would be written in dependsOn.
+func (d Dependency) String() string {
+	switch {
+	case d.Stack != "":
+		return d.Project + ":" + d.Stack
+	case len(d.Stacks) > 0:
+		return fmt.Sprintf("%s %v", d.Project, d.Stacks)
+	default:
+		return d.Project
+	}
+}
+
+type Project struct {
+	Name       string       `yaml:"name"`
+	Stacks     Stacks       `yaml:"stacks"`
+	DependsOn  []Dependency `yaml:"dependsOn"`
+	Dir        string       `yaml:"dir,omitempty"`
+	AWSProfile string       `yaml:"aws_profile,omitempty"`
+	// Config sets Pulumi config on every stack of the project.
+	Config ConfigMap `yaml:"config,omitempty"`
+	// EnvFile is a dotenv file loaded into the env of every stack of the project.
+	EnvFile EnvFile `yaml:"envFile,omitempty"`
+	// Labels group projects so they can share a concurrency limit.
+	Labels []string `yaml:"labels,omitempty"`
+	// Parallel caps how many of the project's stacks run at once. Zero means no limit.
+	Parallel int `yaml:"parallel,omitempty"`
+	// Timeout bounds how long an operation on any of the project's stacks may take.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Env sets environment variables on every stack of the project.
+	Env EnvMap `yaml:"env,omitempty"`
+	// Defaults are the global defaults, copied from the configuration when it is loaded.
+	Defaults Defaults `yaml:"-"`
+}
+
+// Defaults sets env and Pulumi config on every stack, below the project and stack settings.
+type Defaults struct {
+	Env    EnvMap    `yaml:"env,omitempty"`
+	Config ConfigMap `yaml:"config,omitempty"`
+}
+
+// EffectiveAWSProfile returns the AWS profile the named stack runs with, given the
+// AWS_PROFILE in its resolved env. The setting from the more specific level wins: a
+// stack's aws_profile beats an AWS_PROFILE from the project or defaults env, and a
+// project's aws_profile beats one from the defaults env. At the same level AWS_PROFILE in
+// env wins. An empty Value means no profile is configured.
+func (p Project) EffectiveAWSProfile(stack string, env Resolved) Resolved {
+	profile := Resolved{Value: p.AWSProfile, Source: "project aws_profile"}
+	if sc, ok := p.Stack(stack); ok && sc.AWSProfile != "" {
+		profile = Resolved{Value: sc.AWSProfile, Source: "stack aws_profile"}
+	}
+	if env.Value != "" && (profile.Value == "" || sourceLevel(env.Source) >= sourceLevel(profile.Source)) {
+		return env
+	}
+	return profile
+}
+
+// sourceLevel orders the levels named in a Resolved.Source: the global defaults, then the
+// project, then the stack.
+func sourceLevel(source string) int {
+	level, _, _ := strings.Cut(source, " ")
+	switch level {
+	case "stack":
+		return 2
+	case "project":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// StackTimeout returns the timeout for the named stack, with the stack's timeout taking
+// precedence over the project's. Zero means no timeout is configured.
+func (p Project) StackTimeout(stack string) time.Duration {
+	if sc, ok := p.Stack(stack); ok && sc.Timeout > 0 {
+		return sc.Timeout
+	}
+	return p.Timeout
+}
+
+// ResolveStackConfig returns the Pulumi config for the named stack and where each value
+// came from. The stack's config takes precedence over the project's, which takes
+// precedence over the global defaults. Values are interpolated like env values, from the
+// stack's env and then the calling environment, so secrets can be kept out of the file.
+// A value that references a secret env var is stored as a secret, and one that references
+// an external env var is external.
+func (p Project) ResolveStackConfig(stack string) (map[string]Resolved, error) {
+	env, err := p.ResolveStackEnv(stack)
+	if err != nil {
+		return nil, err
+	}
+	r := &envResolver{env: env}
+
+	merged := make(map[string]Resolved)
+	apply := func(values ConfigMap, source string) error {
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			value, err := r.expand(values[k].Value)
+			if err != nil {
+				return fmt.Errorf("%s config %s: %w", source, k, err)
 			}
+			value.Secret = value.Secret || values[k].Secret
+			value.Source = source
+			merged[k] = value
 		}
-		*s = configs
 		return nil
 	}
+	sc, _ := p.Stack(stack)
+	if err := apply(p.Defaults.Config, "defaults"); err != nil {
+		return nil, err
+	}
+	if err := apply(p.Config, "project"); err != nil {
+		return nil, err
+	}
+	if err := apply(sc.Config, "stack"); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
 
-	// Try as []StackConfig (for backwards compatibility)
-	var configs []StackConfig
-	if err := unmarshal(&configs); err == nil {
-		*s = configs
-		return nil
+// StackConfigValues returns the Pulumi config for the named stack. See ResolveStackConfig.
+func (p Project) StackConfigValues(stack string) (ConfigMap, error) {
+	resolved, err := p.ResolveStackConfig(stack)
+	if err != nil {
+		return nil, err
 	}
-	return fmt.Errorf("stacks must be a list of strings or a list of stack config objects")
+	merged := make(ConfigMap, len(resolved))
+	for k, v := range resolved {
+		merged[k] = ConfigValue{Value: v.Value, Secret: v.Secret}
+	}
+	return merged, nil
 }
 
-type Project struct {
-	Name       string   `yaml:"name"`
-	Stacks     Stacks   `yaml:"stacks"`
-	DependsOn  []string `yaml:"dependsOn"`
-	Dir        string   `yaml:"dir,omitempty"`
-	AWSProfile string   `yaml:"aws_profile,omitempty"`
+// Concurrency limits how many stacks run at once.
+type Concurrency struct {
+	// Parallel caps how many stacks run at once across the whole graph. Zero means no limit.
+	Parallel int `yaml:"parallel,omitempty"`
+	// Labels caps how many stacks of the projects carrying each label run at once.
+	Labels map[string]int `yaml:"labels,omitempty"`
+}
+
+// Validate checks that no limit is negative.
+func (c Concurrency) Validate(projects []Project) error {
+	if c.Parallel < 0 {
+		return fmt.Errorf("concurrency parallel must not be negative, got %d", c.Parallel)
+	}
+	for label, limit := range c.Labels {
+		if limit < 0 {
+			return fmt.Errorf("concurrency limit for label %q must not be negative, got %d", label, limit)
+		}
+	}
+	for _, p := range projects {
+		if p.Parallel < 0 {
+			return fmt.Errorf("project %s: parallel must not be negative, got %d", p.Name, p.Parallel)
+		}
+	}
+	return nil
 }
 
 type Config struct {
-	Projects []Project `yaml:"projects"`
+	// Concurrency limits how many stacks run at once.
+	Concurrency Concurrency `yaml:"concurrency,omitempty"`
+	// LogEnvValues lists the env var names whose values are safe to show in logs.
+	// The values of every other env var are redacted.
+	LogEnvValues []string `yaml:"logEnvValues,omitempty"`
+	// Defaults sets env and Pulumi config on every stack.
+	Defaults Defaults `yaml:"defaults,omitempty"`
+	// RetryPolicy sets the retry settings for every stack.
+	RetryPolicy `yaml:",inline"`
+	Projects    []Project `yaml:"projects"`
+}
+
+// Validate checks the settings that cannot be checked while parsing.
+func (c Config) Validate() error {
+	if err := c.Concurrency.Validate(c.Projects); err != nil {
+		return err
+	}
+	if err := c.RetryPolicy.Validate(); err != nil {
+		return err
+	}
+	for _, p := range c.Projects {
+		for _, sc := range p.Stacks {
+			if err := sc.RetryPolicy.Validate(); err != nil {
+				return fmt.Errorf("project %s stack %s: %w", p.Name, sc.Name, err)
+			}
+		}
+	}
+	return nil
 }
 
 type ProjectSource struct {
 	IsGit     bool
 	GitURL    string
 	GitBranch string
+	// GitRef is a tag or commit SHA to check out. It takes precedence over GitBranch.
+	GitRef    string
 	LocalPath string
+	// CheckoutDir is the root of the cloned repository once a Git source has been checked out.
+	CheckoutDir string
+}
+
+// Revision returns the Git revision to check out for this source. An empty revision means
+// the remote's default branch.
+func (s ProjectSource) Revision() string {
+	if s.GitRef != "" {
+		return s.GitRef
+	}
+	return s.GitBranch
 }
@@ -0,0 +1,71 @@
+package project
+
+import "testing"
+
+func TestResolveStackEnvAWSProfile(t *testing.T) {
+	tests := []struct {
+		name       string
+		project    Project
+		want       string
+		wantSource string
+	}{
+		{
+			name:    "no profile",
+			project: Project{Stacks: Stacks{{Name: "prod"}}},
+		},
+		{
+			name:       "project aws_profile beats defaults env",
+			project:    Project{AWSProfile: "prod", Stacks: Stacks{{Name: "prod"}}, Defaults: Defaults{Env: EnvMap{"AWS_PROFILE": {Value: "shared"}}}},
+			want:       "prod",
+			wantSource: "project aws_profile",
+		},
+		{
+			name:       "stack aws_profile beats project env",
+			project:    Project{Env: EnvMap{"AWS_PROFILE": {Value: "project-env"}}, Stacks: Stacks{{Name: "prod", AWSProfile: "stack"}}},
+			want:       "stack",
+			wantSource: "stack aws_profile",
+		},
+		{
+			name:       "stack aws_profile beats project aws_profile",
+			project:    Project{AWSProfile: "project", Stacks: Stacks{{Name: "prod", AWSProfile: "stack"}}},
+			want:       "stack",
+			wantSource: "stack aws_profile",
+		},
+		{
+			name:       "project env beats project aws_profile at the same level",
+			project:    Project{AWSProfile: "project", Env: EnvMap{"AWS_PROFILE": {Value: "project-env"}}, Stacks: Stacks{{Name: "prod"}}},
+			want:       "project-env",
+			wantSource: "project",
+		},
+		{
+			name:       "stack env beats project aws_profile",
+			project:    Project{AWSProfile: "project", Stacks: Stacks{{Name: "prod", Env: EnvMap{"AWS_PROFILE": {Value: "stack-env"}}}}},
+			want:       "stack-env",
+			wantSource: "stack",
+		},
+		{
+			name:       "defaults env without aws_profile",
+			project:    Project{Stacks: Stacks{{Name: "prod"}}, Defaults: Defaults{Env: EnvMap{"AWS_PROFILE": {Value: "shared"}}}},
+			want:       "shared",
+			wantSource: "defaults",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := tt.project.ResolveStackEnv("prod")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := env["AWS_PROFILE"]
+			if tt.want == "" {
+				if ok {
+					t.Fatalf("AWS_PROFILE = %+v, want unset", got)
+				}
+				return
+			}
+			if got.Value != tt.want || got.Source != tt.wantSource {
+				t.Errorf("AWS_PROFILE = %q from %q, want %q from %q", got.Value, got.Source, tt.want, tt.wantSource)
+			}
+		})
+	}
+}
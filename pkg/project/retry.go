@@ -0,0 +1,115 @@
+// pkg/project/retry.go - Retry settings for transient stack failures
+
+package project
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultRetryBackoff is the wait before the first retry when no backoff is configured.
+const DefaultRetryBackoff = 10 * time.Second
+
+// MaxRetryBackoff caps the wait between attempts, however many times it has doubled.
+const MaxRetryBackoff = 10 * time.Minute
+
+// MaxRetries is the largest retry count Validate accepts.
+const MaxRetries = 20
+
+// RetryPolicy controls how a failed stack operation is retried. It can be set at the top
+// of the configuration and on individual stacks; stack settings override global ones.
+type RetryPolicy struct {
+	// Retries is how many times a failed operation is retried. Zero disables retries.
+	Retries *int `yaml:"retries,omitempty"`
+	// RetryBackoff is the wait before the first retry. It doubles for every further attempt.
+	RetryBackoff time.Duration `yaml:"retryBackoff,omitempty"`
+	// RetryOn lists the errors worth retrying, as substrings or as regular expressions
+	// wrapped in slashes, e.g. "/rate ?exceeded/". When empty, every error is retried.
+	RetryOn []string `yaml:"retryOn,omitempty"`
+}
+
+// Merge returns the policy with every setting made in override taking precedence.
+func (p RetryPolicy) Merge(override RetryPolicy) RetryPolicy {
+	if override.Retries != nil {
+		p.Retries = override.Retries
+	}
+	if override.RetryBackoff != 0 {
+		p.RetryBackoff = override.RetryBackoff
+	}
+	if override.RetryOn != nil {
+		p.RetryOn = override.RetryOn
+	}
+	return p
+}
+
+// Attempts returns the total number of attempts the policy allows, including the first.
+func (p RetryPolicy) Attempts() int {
+	if p.Retries == nil || *p.Retries < 0 {
+		return 1
+	}
+	return *p.Retries + 1
+}
+
+// Backoff returns how long to wait after the given failed attempt, counting from 1. It
+// never exceeds MaxRetryBackoff.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.RetryBackoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	for i := 1; i < attempt && backoff < MaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, MaxRetryBackoff)
+}
+
+// Retryable reports whether an error matches the policy's retryable errors.
+func (p RetryPolicy) Retryable(err error) bool {
+	if len(p.RetryOn) == 0 {
+		return true
+	}
+	msg := err.Error()
+	for _, pattern := range p.RetryOn {
+		if expr, ok := regexPattern(pattern); ok {
+			if re, err := regexp.Compile(expr); err == nil && re.MatchString(msg) {
+				return true
+			}
+			continue
+		}
+		if strings.Contains(msg, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the retry count and that every regular expression compiles.
+func (p RetryPolicy) Validate() error {
+	if p.Retries != nil && *p.Retries < 0 {
+		return fmt.Errorf("retries must not be negative, got %d", *p.Retries)
+	}
+	if p.Retries != nil && *p.Retries > MaxRetries {
+		return fmt.Errorf("retries must be at most %d, got %d", MaxRetries, *p.Retries)
+	}
+	if p.RetryBackoff < 0 {
+		return fmt.Errorf("retryBackoff must not be negative, got %s", p.RetryBackoff)
+	}
+	for _, pattern := range p.RetryOn {
+		if expr, ok := regexPattern(pattern); ok {
+			if _, err := regexp.Compile(expr); err != nil {
+				return fmt.Errorf("invalid retryOn pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// regexPattern returns the expression inside a pattern written as /expr/.
+func regexPattern(pattern string) (string, bool) {
+	if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		return pattern[1 : len(pattern)-1], true
+	}
+	return "", false
+}
@@ -0,0 +1,60 @@
+package project
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		backoff time.Duration
+		attempt int
+		want    time.Duration
+	}{
+		{backoff: 0, attempt: 1, want: DefaultRetryBackoff},
+		{backoff: 0, attempt: 3, want: 4 * DefaultRetryBackoff},
+		{backoff: time.Second, attempt: 1, want: time.Second},
+		{backoff: time.Second, attempt: 4, want: 8 * time.Second},
+		{backoff: time.Minute, attempt: 5, want: MaxRetryBackoff},
+		{backoff: time.Second, attempt: 100, want: MaxRetryBackoff},
+		{backoff: time.Second, attempt: 1 << 30, want: MaxRetryBackoff},
+		{backoff: time.Hour, attempt: 1, want: MaxRetryBackoff},
+	}
+	for _, tt := range tests {
+		p := RetryPolicy{RetryBackoff: tt.backoff}
+		if got := p.Backoff(tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%d) with retryBackoff %s = %s, want %s", tt.attempt, tt.backoff, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	retries := func(n int) *int { return &n }
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		wantErr string
+	}{
+		{name: "unset", policy: RetryPolicy{}},
+		{name: "maximum retries", policy: RetryPolicy{Retries: retries(MaxRetries)}},
+		{name: "negative retries", policy: RetryPolicy{Retries: retries(-1)}, wantErr: "retries must not be negative"},
+		{name: "too many retries", policy: RetryPolicy{Retries: retries(MaxRetries + 1)}, wantErr: "retries must be at most 20, got 21"},
+		{name: "negative backoff", policy: RetryPolicy{RetryBackoff: -time.Second}, wantErr: "retryBackoff must not be negative"},
+		{name: "invalid pattern", policy: RetryPolicy{RetryOn: []string{"/(/"}}, wantErr: `invalid retryOn pattern "/(/"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
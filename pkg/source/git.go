@@ -0,0 +1,67 @@
+// pkg/source/git.go - Check out Git-backed project sources
+package source
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Checkout clones the repository at url into a temporary directory and checks out ref,
+// which may be a branch, a tag or a (possibly abbreviated) commit SHA. The returned
+// cleanup function removes the checkout and is safe to call even when an error is returned.
+func Checkout(ctx context.Context, url string, ref string) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "pedloy-")
+	if err != nil {
+		return "", func() {}, fmt.Errorf("failed to create checkout directory: %w", err)
+	}
+	cleanup := func() {
+		os.RemoveAll(dir)
+	}
+
+	// Clone every ref so that branches, tags and arbitrary commits can all be resolved
+	repo, err := git.PlainCloneContext(ctx, dir, false, &git.CloneOptions{
+		RemoteName: "origin",
+		URL:        url,
+		Tags:       git.AllTags,
+	})
+	if err != nil {
+		return "", cleanup, fmt.Errorf("failed to clone %s: %w", url, err)
+	}
+
+	if ref != "" {
+		hash, err := resolveRef(repo, ref)
+		if err != nil {
+			return "", cleanup, err
+		}
+
+		w, err := repo.Worktree()
+		if err != nil {
+			return "", cleanup, fmt.Errorf("failed to open worktree: %w", err)
+		}
+		if err := w.Checkout(&git.CheckoutOptions{Hash: *hash, Force: true}); err != nil {
+			return "", cleanup, fmt.Errorf("failed to check out %s: %w", ref, err)
+		}
+	}
+
+	return dir, cleanup, nil
+}
+
+// resolveRef finds the commit for a branch, tag or commit SHA in a fresh clone.
+// Branches only exist as remote-tracking refs after cloning, so they are tried last.
+func resolveRef(repo *git.Repository, ref string) (*plumbing.Hash, error) {
+	candidates := []string{
+		ref,
+		"refs/tags/" + ref,
+		"refs/remotes/origin/" + ref,
+	}
+	for _, candidate := range candidates {
+		if hash, err := repo.ResolveRevision(plumbing.Revision(candidate)); err == nil {
+			return hash, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to resolve git ref %q", ref)
+}
@@ -0,0 +1,122 @@
+package source
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testRepo is a bare repository on disk with a known history:
+//
+//	main:    first (tagged v1.0.0) -> second
+//	feature: second -> third
+type testRepo struct {
+	url                  string
+	first, second, third string
+}
+
+func newTestRepo(t *testing.T) testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root := t.TempDir()
+	work := filepath.Join(root, "work")
+	bare := filepath.Join(root, "repo.git")
+
+	run := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+			"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+		)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	commit := func(content string) string {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(work, "version.txt"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		run(work, "add", "version.txt")
+		run(work, "commit", "-q", "-m", content)
+		return run(work, "rev-parse", "HEAD")
+	}
+
+	if err := os.Mkdir(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	run(work, "init", "-q", "-b", "main")
+	repo := testRepo{url: bare}
+	repo.first = commit("first")
+	run(work, "tag", "v1.0.0")
+	repo.second = commit("second")
+	run(work, "checkout", "-q", "-b", "feature")
+	repo.third = commit("third")
+
+	run(root, "init", "-q", "--bare", "-b", "main", bare)
+	run(work, "push", "-q", bare, "main", "feature", "--tags")
+	return repo
+}
+
+func TestCheckout(t *testing.T) {
+	repo := newTestRepo(t)
+
+	tests := []struct {
+		name string
+		ref  string
+		want string
+	}{
+		{name: "default branch", ref: "", want: "second"},
+		{name: "branch", ref: "main", want: "second"},
+		{name: "non-default branch", ref: "feature", want: "third"},
+		{name: "tag", ref: "v1.0.0", want: "first"},
+		{name: "full sha", ref: repo.first, want: "first"},
+		{name: "short sha", ref: repo.third[:7], want: "third"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, cleanup, err := Checkout(context.Background(), repo.url, tt.ref)
+			defer cleanup()
+			if err != nil {
+				t.Fatalf("Checkout(%q): %v", tt.ref, err)
+			}
+
+			got, err := os.ReadFile(filepath.Join(dir, "version.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Checkout(%q) checked out %q, want %q", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckoutUnknownRef(t *testing.T) {
+	repo := newTestRepo(t)
+
+	dir, cleanup, err := Checkout(context.Background(), repo.url, "does-not-exist")
+	if err == nil {
+		t.Fatal("expected an error for an unknown ref")
+	}
+	if !strings.Contains(err.Error(), `failed to resolve git ref "does-not-exist"`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if dir != "" {
+		t.Errorf("expected no directory, got %q", dir)
+	}
+
+	// Cleanup is safe to call after an error
+	cleanup()
+}
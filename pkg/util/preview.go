@@ -7,13 +7,17 @@ import (
 	"github.com/jaxxstorm/pedloy/pkg/project"
 )
 
-// PreviewExecution provides a preview of the execution plan.
-func PreviewExecution(projects []project.Project, mode string) error {
-	// Get execution groups
-	executionGroups, err := graph.GetExecutionGroups(projects)
+// PreviewExecution provides a preview of the execution plan for the selected stacks.
+func PreviewExecution(projects []project.Project, mode string, sel graph.Selection) error {
+	g, err := graph.Build(projects)
 	if err != nil {
 		return fmt.Errorf("failed to determine execution groups: %w", err)
 	}
+	g, err = g.Select(sel)
+	if err != nil {
+		return fmt.Errorf("failed to select stacks: %w", err)
+	}
+	executionGroups := g.Stages()
 
 	// Print the execution groups for preview
 	fmt.Printf("\n%s Plan:\n", mode)
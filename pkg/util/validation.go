@@ -3,25 +3,67 @@ package util
 import (
 	"fmt"
 
+	"github.com/jaxxstorm/pedloy/pkg/graph"
 	"github.com/jaxxstorm/pedloy/pkg/project"
 )
 
-// ValidateDependencies checks for missing dependencies in the project configuration.
+// ValidateDependencies checks for missing dependencies and dependency cycles in the project configuration.
 func ValidateDependencies(projects []project.Project) error {
-	// Build a set of valid project names
+	// Build a set of valid project names and project:stack combinations
 	projectNames := make(map[string]struct{})
+	stacks := make(map[string]struct{})
 	for _, project := range projects {
 		projectNames[project.Name] = struct{}{}
+		for _, sc := range project.Stacks {
+			stacks[project.Name+":"+sc.Name] = struct{}{}
+		}
 	}
 
 	// Check dependencies for each project
 	for _, project := range projects {
 		for _, dep := range project.DependsOn {
-			if _, exists := projectNames[dep]; !exists {
-				return fmt.Errorf("project %q depends on missing project %q", project.Name, dep)
+			if _, exists := projectNames[dep.Project]; !exists {
+				return fmt.Errorf("project %q depends on missing project %q", project.Name, dep.Project)
+			}
+
+			// Explicitly named stacks must exist
+			for _, sc := range project.Stacks {
+				depStack, explicit := dep.StackFor(sc.Name)
+				if _, exists := stacks[dep.Project+":"+depStack]; explicit && !exists {
+					return fmt.Errorf("stack %q depends on missing stack %q", project.Name+":"+sc.Name, dep.Project+":"+depStack)
+				}
 			}
 		}
 	}
 
+	// Check that stack outputs are read from stacks that exist
+	for _, project := range projects {
+		for _, sc := range project.Stacks {
+			vertex := project.Name + ":" + sc.Name
+			for _, ref := range sc.ConfigFrom {
+				if ref.Key == "" || ref.Output == "" {
+					return fmt.Errorf("stack %q has a configFrom entry without a key or output", vertex)
+				}
+				depProject, depStack := ref.Source(sc.Name)
+				source := depProject + ":" + depStack
+				if _, exists := stacks[source]; !exists {
+					return fmt.Errorf("stack %q reads config %q from missing stack %q", vertex, ref.Key, source)
+				}
+				if source == vertex {
+					return fmt.Errorf("stack %q reads config %q from its own outputs", vertex, ref.Key)
+				}
+			}
+		}
+	}
+
+	// Report every cycle with its full path before any Pulumi work starts
+	cycles, err := graph.Cycles(projects)
+	if err != nil {
+		return err
+	}
+	if len(cycles) > 0 {
+		return &graph.CycleError{Cycles: cycles}
+	}
+
 	return nil
 }